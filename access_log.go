@@ -0,0 +1,225 @@
+package goweb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// fields of access logs
+const (
+	LogFieldTime       = "time"
+	LogFieldStatus     = "status"
+	LogFieldSize       = "size"
+	LogFieldMethod     = "method"
+	LogFieldURI        = "uri"
+	LogFieldProto      = "proto"
+	LogFieldHost       = "host"
+	LogFieldRoute      = "route"
+	LogFieldParams     = "params"
+	LogFieldDuration   = "duration"
+	LogFieldRemoteAddr = "remote_addr"
+	LogFieldUserAgent  = "user_agent"
+	LogFieldReferer    = "referer"
+	LogFieldRequestID  = "request_id"
+	LogFieldError      = "error"
+)
+
+// allLogFields all fields in the order they are logged
+var allLogFields = []string{
+	LogFieldTime, LogFieldStatus, LogFieldSize, LogFieldMethod, LogFieldURI, LogFieldProto, LogFieldHost,
+	LogFieldRoute, LogFieldParams, LogFieldDuration, LogFieldRemoteAddr, LogFieldUserAgent, LogFieldReferer,
+	LogFieldRequestID, LogFieldError,
+}
+
+// AccessLogConfig access log settings of a router
+// Fields selects fields logged by JSON and slog log handlers, all fields if empty.
+// SampleRate is the fraction of requests logged, all requests if 0. Requests failed with an error or a 5xx status are always logged.
+type AccessLogConfig struct {
+	Fields     []string
+	SampleRate float64
+}
+
+// sampled test if the access log of the request should be written
+func (c *AccessLogConfig) sampled(ctx *RequestContext) bool {
+	if c == nil || c.SampleRate <= 0 || c.SampleRate >= 1 {
+		return true
+	}
+	if ctx.Error != nil || ctx.StatusCode >= 500 {
+		return true
+	}
+	return rand.Float64() < c.SampleRate
+}
+
+// LogField a field of an access log
+type LogField struct {
+	Key   string
+	Value interface{}
+}
+
+// AccessLogFields fields of the access log of the request, selected by the AccessLogConfig of the matched router
+// Empty route, params, referer, request ID and error are omitted.
+func (c *RequestContext) AccessLogFields() []LogField {
+	names := allLogFields
+	if c.Router != nil && c.Router.Config != nil && c.Router.Config.AccessLog != nil && len(c.Router.Config.AccessLog.Fields) > 0 {
+		names = c.Router.Config.AccessLog.Fields
+	}
+
+	fields := make([]LogField, 0, len(names))
+	for _, name := range names {
+		var v interface{}
+		switch name {
+		case LogFieldTime:
+			v = c.StartTime
+		case LogFieldStatus:
+			v = c.StatusCode
+		case LogFieldSize:
+			v = c.ResponseSize
+		case LogFieldMethod:
+			v = c.Method
+		case LogFieldURI:
+			v = c.URI
+		case LogFieldProto:
+			v = c.Proto
+		case LogFieldHost:
+			v = c.Host
+		case LogFieldRoute:
+			if route := c.Route(); route != "" {
+				v = route
+			}
+		case LogFieldParams:
+			if c.Request != nil && len(c.Request.pathParam) > 0 {
+				v = c.Request.pathParam
+			}
+		case LogFieldDuration:
+			v = time.Since(c.StartTime).Seconds()
+		case LogFieldRemoteAddr:
+			v = c.RemoteAddr
+		case LogFieldUserAgent:
+			v = c.UserAgent
+		case LogFieldReferer:
+			if c.Request != nil && c.Request.Header("Referer") != "" {
+				v = c.Request.Header("Referer")
+			}
+		case LogFieldRequestID:
+			if c.RequestID != "" {
+				v = c.RequestID
+			}
+		case LogFieldError:
+			if c.Error != nil {
+				v = c.Error.Error()
+			}
+		}
+		if v != nil {
+			fields = append(fields, LogField{Key: name, Value: v})
+		}
+	}
+	return fields
+}
+
+// lockedWriter serialize writes of log lines from concurrent requests
+type lockedWriter struct {
+	w     io.Writer
+	mutex sync.Mutex
+}
+
+func (lw *lockedWriter) writeLine(line []byte) {
+	lw.mutex.Lock()
+	defer lw.mutex.Unlock()
+	lw.w.Write(line)
+}
+
+// apacheLogLine format a line of the Apache common log format, with referer and user agent in combined format
+func apacheLogLine(ctx *RequestContext, combined bool) []byte {
+	host, _, err := net.SplitHostPort(ctx.RemoteAddr)
+	if err != nil {
+		host = ctx.RemoteAddr
+	}
+	size := "-"
+	if ctx.ResponseSize > 0 {
+		size = strconv.FormatInt(ctx.ResponseSize, 10)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(host + " - - [" + ctx.StartTime.Format("02/Jan/2006:15:04:05 -0700") + "] ")
+	b.WriteString(strconv.Quote(ctx.Method+" "+ctx.URI+" "+ctx.Proto) + " " + strconv.Itoa(ctx.StatusCode) + " " + size)
+	if combined {
+		referer := ""
+		if ctx.Request != nil {
+			referer = ctx.Request.Header("Referer")
+		}
+		b.WriteString(" " + strconv.Quote(referer) + " " + strconv.Quote(ctx.UserAgent))
+	}
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+// NewCommonLogHandler create a log handler writing access logs to w in Apache common log format
+func NewCommonLogHandler(w io.Writer) LogHandlerFunc {
+	lw := &lockedWriter{w: w}
+	return func(ctx *RequestContext) {
+		lw.writeLine(apacheLogLine(ctx, false))
+	}
+}
+
+// NewCombinedLogHandler create a log handler writing access logs to w in Apache combined log format
+func NewCombinedLogHandler(w io.Writer) LogHandlerFunc {
+	lw := &lockedWriter{w: w}
+	return func(ctx *RequestContext) {
+		lw.writeLine(apacheLogLine(ctx, true))
+	}
+}
+
+// NewJSONLogHandler create a log handler writing access logs to w as JSON lines, see RequestContext.AccessLogFields
+func NewJSONLogHandler(w io.Writer) LogHandlerFunc {
+	lw := &lockedWriter{w: w}
+	return func(ctx *RequestContext) {
+		var b bytes.Buffer
+		b.WriteByte('{')
+		for i, field := range ctx.AccessLogFields() {
+			key, _ := json.Marshal(field.Key)
+			value, err := json.Marshal(field.Value)
+			if err != nil {
+				value, _ = json.Marshal(err.Error())
+			}
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.Write(key)
+			b.WriteByte(':')
+			b.Write(value)
+		}
+		b.WriteString("}\n")
+		lw.writeLine(b.Bytes())
+	}
+}
+
+// NewSlogLogHandler create a log handler writing access logs to logger, see RequestContext.AccessLogFields
+// Requests failed with an error or a 5xx status are logged at error level, others at info level.
+func NewSlogLogHandler(logger *slog.Logger) LogHandlerFunc {
+	return func(ctx *RequestContext) {
+		fields := ctx.AccessLogFields()
+		attrs := make([]slog.Attr, 0, len(fields))
+		for _, field := range fields {
+			if field.Key == LogFieldTime {
+				// slog records its own time
+				continue
+			}
+			attrs = append(attrs, slog.Any(field.Key, field.Value))
+		}
+
+		level := slog.LevelInfo
+		if ctx.Error != nil || ctx.StatusCode >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(context.Background(), level, "access", attrs...)
+	}
+}
@@ -0,0 +1,81 @@
+package goweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testAccessLogServer(logHandler LogHandlerFunc) *AppServer {
+	server := NewAppServer(&AppServerConfig{LogHandlerFunc: logHandler})
+	server.AddMethodRouter(HttpGet, "/users/:id", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return resp.WriteString("user " + req.PathParam("id"))
+	}, &RouterConfig{AccessLog: &AccessLogConfig{Fields: []string{LogFieldStatus, LogFieldSize, LogFieldRoute, LogFieldParams, LogFieldRequestID}}})
+	server.AddMethodRouter(HttpGet, "/fail", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return NewHTTPError(503, "unavailable")
+	}, nil)
+	server.AddMethodRouter(HttpGet, "/sampled", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return resp.WriteString("ok")
+	}, &RouterConfig{AccessLog: &AccessLogConfig{SampleRate: 0.000001}})
+	server.prepare()
+	return server
+}
+
+func testAccessLog(server *AppServer, path string) {
+	req := httptest.NewRequest(HttpGet, path, nil)
+	req.Header.Set("Referer", "http://example.com/")
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set("X-Request-ID", "req-1")
+	server.ServeMux.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestApacheLogHandlers(t *testing.T) {
+	var buf bytes.Buffer
+	server := testAccessLogServer(NewCommonLogHandler(&buf))
+	testAccessLog(server, "/users/7")
+	line := buf.String()
+	assert(strings.HasPrefix(line, "192.0.2.1 - - [") && strings.HasSuffix(line, `] "GET /users/7 HTTP/1.1" 200 6`+"\n"), "common log wrong: "+line)
+
+	buf.Reset()
+	server = testAccessLogServer(NewCombinedLogHandler(&buf))
+	testAccessLog(server, "/fail")
+	line = buf.String()
+	assert(strings.Contains(line, `] "GET /fail HTTP/1.1" 503 `), "combined log status wrong: "+line)
+	assert(strings.HasSuffix(line, ` "http://example.com/" "test-agent"`+"\n"), "combined log wrong: "+line)
+}
+
+func TestJSONLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	server := testAccessLogServer(NewJSONLogHandler(&buf))
+	testAccessLog(server, "/users/7")
+	assert(buf.String() == `{"status":200,"size":6,"route":"/users/:id","params":{"id":"7"},"request_id":"req-1"}`+"\n", "selected fields wrong: "+buf.String())
+
+	buf.Reset()
+	testAccessLog(server, "/fail")
+	entry := make(map[string]interface{}, 0)
+	assert(json.Unmarshal(buf.Bytes(), &entry) == nil, "JSON log invalid")
+	assert(entry["status"] == 503.0 && entry["route"] == "/fail" && entry["error"] == "unavailable" && entry["referer"] == "http://example.com/", "all fields wrong")
+	assert(entry["request_id"] == "req-1", "request ID should be logged")
+
+	buf.Reset()
+	for i := 0; i < 20; i++ {
+		testAccessLog(server, "/sampled")
+	}
+	assert(buf.Len() == 0, "requests should be sampled")
+}
+
+func TestSlogLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	server := testAccessLogServer(NewSlogLogHandler(logger))
+	testAccessLog(server, "/users/7")
+	line := buf.String()
+	assert(strings.Contains(line, "level=INFO msg=access status=200 size=6 route=/users/:id params=map[id:7] request_id=req-1"), "slog attributes wrong: "+line)
+
+	buf.Reset()
+	testAccessLog(server, "/fail")
+	assert(strings.Contains(buf.String(), "level=ERROR") && strings.Contains(buf.String(), "error=unavailable"), "failed request should be logged as error")
+}
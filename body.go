@@ -0,0 +1,141 @@
+package goweb
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// isJSONMediaType test if media type is application/json or application/*+json
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// isXMLMediaType test if media type is application/xml, text/xml or application/*+xml
+func isXMLMediaType(mediaType string) bool {
+	return mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+// parseMediaType get the lower cased media type of a Content-Type header, parameters are ignored
+func parseMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.TrimSpace(strings.Split(contentType, ";")[0])
+	}
+	return strings.ToLower(mediaType)
+}
+
+// decodeJSONValues decode a JSON object and flatten it to url.Values
+// Nested objects are addressed with dotted names (address.city),
+// arrays of values become repeated values, arrays of objects are addressed with indexes (items[0].sku).
+// Numbers in exponent form are written in decimal form, so 1e3 binds to integer fields.
+// Null members are skipped, null elements of arrays become empty values to keep positions of other elements.
+// Keys containing dots or brackets are not escaped, {"a.b":1} and {"a":{"b":1}} give the same name a.b.
+func decodeJSONValues(r io.Reader) (url.Values, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var body interface{}
+	if err := dec.Decode(&body); err != nil {
+		return nil, err
+	}
+	obj, ok := body.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("JSON body must be an object")
+	}
+
+	values := make(url.Values, 0)
+	for k, v := range obj {
+		flattenJSONValue(k, v, values)
+	}
+	return values, nil
+}
+
+func flattenJSONValue(name string, v interface{}, values url.Values) {
+	switch vv := v.(type) {
+	case nil:
+	case string:
+		values.Add(name, vv)
+	case json.Number:
+		values.Add(name, jsonNumberString(vv))
+	case bool:
+		values.Add(name, strconv.FormatBool(vv))
+	case map[string]interface{}:
+		for k, item := range vv {
+			flattenJSONValue(name+"."+k, item, values)
+		}
+	case []interface{}:
+		for i, item := range vv {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				flattenJSONValue(name+"["+strconv.Itoa(i)+"]", item, values)
+			case nil:
+				values.Add(name, "")
+			default:
+				flattenJSONValue(name, item, values)
+			}
+		}
+	}
+}
+
+// jsonNumberString write a JSON number in exponent form (1e3) in decimal form (1000)
+func jsonNumberString(n json.Number) string {
+	s := n.String()
+	if !strings.ContainsAny(s, "eE") {
+		return s
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return s
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// decodeXMLValues decode a XML document and flatten children of the root element to url.Values
+// Nested elements are addressed with dotted names (address.city), repeated elements become repeated values.
+func decodeXMLValues(r io.Reader) (url.Values, error) {
+	dec := xml.NewDecoder(r)
+	values := make(url.Values, 0)
+
+	names := make([]string, 0)
+	hasChild := make([]bool, 0)
+	var text strings.Builder
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if len(hasChild) > 0 {
+				hasChild[len(hasChild)-1] = true
+			}
+			names = append(names, t.Name.Local)
+			hasChild = append(hasChild, false)
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			last := len(names) - 1
+			// leaf elements under the root element hold values
+			if last > 0 && !hasChild[last] {
+				values.Add(strings.Join(names[1:], "."), strings.TrimSpace(text.String()))
+			}
+			names = names[:last]
+			hasChild = hasChild[:last]
+			text.Reset()
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,111 @@
+package goweb
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type createPostForm struct {
+	Title   string   `form:"title,required"`
+	Views   int      `form:"views"`
+	Draft   bool     `form:"draft"`
+	Tags    []string `form:"tags"`
+	UserID  int      `form:"X-User-Id,required,header"`
+	SiteID  string   `form:"siteId,path"`
+	Preview string   `form:"preview"`
+}
+
+func testBodyRequest(contentType string, body string) *Request {
+	req := httptest.NewRequest(HttpPost, "/sites/s1/posts?preview=yes&title=ignored", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-User-Id", "7")
+	r := NewRequest(req)
+	r.pathParam = map[string]string{"siteId": "s1"}
+	if err := r.ParseParam(); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func TestDecodeJSONValues(t *testing.T) {
+	values, err := decodeJSONValues(strings.NewReader(`{"a":"x","n":1.5,"b":true,"z":null,"tags":["t1","t2"],"address":{"city":"sh"},"items":[{"sku":"k1"},{"sku":"k2"}]}`))
+	assert(err == nil, "decode JSON failed")
+	assert(values.Get("a") == "x" && values.Get("n") == "1.5" && values.Get("b") == "true", "JSON scalars wrong")
+	_, found := values["z"]
+	assert(!found, "JSON null should be skipped")
+	assert(strings.Join(values["tags"], ",") == "t1,t2", "JSON array wrong")
+	assert(values.Get("address.city") == "sh", "JSON object wrong")
+	assert(values.Get("items[1].sku") == "k2", "JSON array of objects wrong")
+
+	values, err = decodeJSONValues(strings.NewReader(`{"count":1e3,"ratio":2.5E-1,"ids":[1,null,3],"a.b":1}`))
+	assert(err == nil && values.Get("count") == "1000" && values.Get("ratio") == "0.25", "JSON numbers in exponent form wrong")
+	assert(strings.Join(values["ids"], ",") == "1,,3", "null elements should keep positions")
+	assert(values.Get("a.b") == "1", "dotted JSON key wrong")
+
+	_, err = decodeJSONValues(strings.NewReader(`["a"]`))
+	assert(err != nil, "JSON body must be an object")
+}
+
+func TestDecodeXMLValues(t *testing.T) {
+	values, err := decodeXMLValues(strings.NewReader(`<post><title> hello </title><tags>t1</tags><tags>t2</tags><address><city>sh</city></address></post>`))
+	assert(err == nil, "decode XML failed")
+	assert(values.Get("title") == "hello", "XML value wrong")
+	assert(strings.Join(values["tags"], ",") == "t1,t2", "XML repeated elements wrong")
+	assert(values.Get("address.city") == "sh", "XML nested element wrong")
+}
+
+func TestFillFormFromJSON(t *testing.T) {
+	r := testBodyRequest("application/json; charset=utf-8", `{"title":"hello","views":3,"draft":true,"tags":["a","b"]}`)
+	form := &createPostForm{}
+	err := r.FillForm(form)
+	assert(err == nil, "fill form from JSON failed")
+	assert(form.Title == "hello" && form.Views == 3 && form.Draft, "JSON fields wrong")
+	assert(len(form.Tags) == 2 && form.Tags[1] == "b", "JSON array field wrong")
+	assert(form.UserID == 7 && form.SiteID == "s1", "header and path fields wrong")
+	assert(form.Preview == "yes", "QueryString should be used if param not in body")
+
+	r = testBodyRequest("application/json", `{"title":"hello","tags":["a,b","c"]}`)
+	form = &createPostForm{}
+	err = r.FillForm(form)
+	assert(err == nil && len(form.Tags) == 2 && form.Tags[0] == "a,b" && form.Tags[1] == "c", "JSON array elements should not be split")
+
+	r = testBodyRequest("application/json", `{"title":"hello","views":1e3}`)
+	form = &createPostForm{}
+	err = r.FillForm(form)
+	assert(err == nil && form.Views == 1000, "JSON number in exponent form should bind to int")
+
+	r = testBodyRequest("application/json", `{"id":[1,null,3]}`)
+	mv := &multiValueForm{}
+	err = r.BindBody(mv)
+	assert(err == nil && len(mv.IDs) == 3 && mv.IDs[1] == 0 && mv.IDs[2] == 3, "null elements should bind to zero values")
+
+	r = testBodyRequest("application/json", `{"title":"hello"}`)
+	form = &createPostForm{}
+	err = r.BindBody(form)
+	assert(err == nil && form.Title == "hello" && form.Preview == "" && form.UserID == 7, "BindBody should only use body")
+
+	r = testBodyRequest("application/json", `{"views":3}`)
+	err = r.BindBody(&createPostForm{})
+	var fe *FormError
+	ok := errors.As(err, &fe)
+	assert(ok && fe.Type == FormErrMissingRequired && fe.FieldName == "title", "required field not enforced")
+
+	r = testBodyRequest("application/json", `{"title":`)
+	err = r.FillForm(&createPostForm{})
+	fe, ok = err.(*FormError)
+	assert(ok && fe.Type == FormErrInvalidBody && AsHTTPError(err).Status == 400, "malformed body should be a form error")
+}
+
+func TestFillFormFromXML(t *testing.T) {
+	r := testBodyRequest("application/xml", `<post><title>hello</title><tags>a</tags><tags>b</tags></post>`)
+	form := &createPostForm{}
+	err := r.FillForm(form)
+	assert(err == nil && form.Title == "hello" && len(form.Tags) == 2, "fill form from XML failed")
+
+	r = testBodyRequest("application/x-www-form-urlencoded", "title=hello")
+	err = r.BindBody(&createPostForm{})
+	assert(errors.Cause(err) == ErrUnsupportedContentType && AsHTTPError(err).Status == 415, "BindBody should reject non structured body")
+}
@@ -0,0 +1,305 @@
+package goweb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CompressConfig config for the Compress middleware
+// Brotli is not supported, as there is no encoder for it in the standard library.
+type CompressConfig struct {
+	Level        int      // compression level of compress/flate, gzip.DefaultCompression if 0
+	MinSize      int      // responses smaller than MinSize bytes are not compressed, 1024 if 0, a negative MinSize compresses responses of any size
+	ContentTypes []string // media types to compress, a type ending with / matches all subtypes, DefaultCompressContentTypes if nil
+}
+
+// DefaultCompressContentTypes media types compressed by default, media types with +json or +xml suffix are also compressed
+var DefaultCompressContentTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// content encodings supported by the Compress middleware, in the order of preference
+var compressEncodings = []string{"gzip", "deflate"}
+
+// negotiateEncoding select the content encoding the client prefers most according to the Accept-Encoding header
+// An empty string is returned if none of offers is acceptable.
+func negotiateEncoding(acceptEncoding string, offers []string) string {
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, matched := 0.0, false
+		for _, item := range strings.Split(acceptEncoding, ",") {
+			params := strings.Split(item, ";")
+			coding := strings.ToLower(strings.TrimSpace(params[0]))
+			if coding != offer && (coding != "*" || matched) {
+				continue
+			}
+			itemQ := 1.0
+			for _, param := range params[1:] {
+				param = strings.TrimSpace(param)
+				if v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); strings.HasPrefix(param, "q=") && err == nil {
+					itemQ = v
+				}
+			}
+			// an exact coding overrides *
+			q, matched = itemQ, coding == offer
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// Compress create a middleware compressing responses with gzip or deflate according to the Accept-Encoding header
+// Responses are buffered until MinSize bytes are written, responses already encoded, without body, supporting ranges
+// or of media types not in ContentTypes are not compressed. Strong ETags of compressed responses are made weak.
+func Compress(config *CompressConfig) Middleware {
+	c := CompressConfig{}
+	if config != nil {
+		c = *config
+	}
+	if c.Level == 0 {
+		c.Level = gzip.DefaultCompression
+	}
+	if c.MinSize == 0 {
+		c.MinSize = 1024
+	} else if c.MinSize < 0 {
+		c.MinSize = 0
+	}
+	if c.ContentTypes == nil {
+		c.ContentTypes = DefaultCompressContentTypes
+	}
+	pools := map[string]*sync.Pool{
+		"gzip": {New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(io.Discard, c.Level)
+			return w
+		}},
+		"deflate": {New: func() interface{} {
+			w, _ := zlib.NewWriterLevel(io.Discard, c.Level)
+			return w
+		}},
+	}
+
+	return func(next RequestHandlerFunc) RequestHandlerFunc {
+		return func(req *Request, resp *Response, ctx *RequestContext) error {
+			addVary(resp.Header(), "Accept-Encoding")
+			encoding := negotiateEncoding(req.Header("Accept-Encoding"), compressEncodings)
+			if encoding == "" || req.Req.Method == HttpHead {
+				return next(req, resp, ctx)
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: resp.Writer,
+				config:         &c,
+				encoding:       encoding,
+				pool:           pools[encoding],
+			}
+			resp.Writer = cw
+			sizeBefore := ctx.ResponseSize
+			defer func() {
+				resp.Writer = cw.ResponseWriter
+				cw.close()
+				// access logs report bytes sent to the client rather than bytes before compressing
+				ctx.ResponseSize = sizeBefore + cw.written
+			}()
+			return next(req, resp, ctx)
+		}
+	}
+}
+
+// resettableEncoder gzip.Writer and zlib.Writer
+type resettableEncoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressWriter buffer the response until it is large enough, then compress it if it is compressible
+type compressWriter struct {
+	http.ResponseWriter
+	config     *CompressConfig
+	encoding   string
+	pool       *sync.Pool
+	statusCode int
+	buf        []byte
+	decided    bool
+	encoder    resettableEncoder
+	written    int64 // bytes written to the underlying writer
+}
+
+// writeRaw write data to the underlying writer, the encoder writes compressed data through it
+func (cw *compressWriter) writeRaw(data []byte) (int, error) {
+	n, err := cw.ResponseWriter.Write(data)
+	cw.written += int64(n)
+	return n, err
+}
+
+// rawWriter io.Writer writing to the underlying writer of a compressWriter
+type rawWriter struct {
+	cw *compressWriter
+}
+
+func (w rawWriter) Write(data []byte) (int, error) {
+	return w.cw.writeRaw(data)
+}
+
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if cw.decided || cw.statusCode != 0 {
+		return
+	}
+	if isInformational(statusCode) {
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	cw.statusCode = statusCode
+	if !bodyAllowed(statusCode) {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(data []byte) (int, error) {
+	if cw.statusCode == 0 {
+		cw.statusCode = http.StatusOK
+	}
+	if !cw.decided {
+		cw.buf = append(cw.buf, data...)
+		if len(cw.buf) < cw.config.MinSize {
+			return len(data), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+
+	if cw.encoder != nil {
+		return cw.encoder.Write(data)
+	}
+	return cw.writeRaw(data)
+}
+
+// bodyAllowed test if a response of the status can have a body
+func bodyAllowed(statusCode int) bool {
+	return statusCode >= 200 && statusCode != http.StatusNoContent && statusCode != http.StatusNotModified
+}
+
+// compressible test if the response should be compressed, the Content-Type is detected from buffered data if not set
+// Partial content and responses supporting ranges are not compressed.
+func (cw *compressWriter) compressible() bool {
+	header := cw.Header()
+	if header.Get("Content-Encoding") != "" || !bodyAllowed(cw.statusCode) {
+		return false
+	}
+	// ranges are offsets of the identity body, compressing would make them wrong
+	if cw.statusCode == http.StatusPartialContent || header.Get("Content-Range") != "" || header.Get("Accept-Ranges") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(cw.buf)
+		header.Set("Content-Type", contentType)
+	}
+
+	mediaType := parseMediaType(contentType)
+	if strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	for _, t := range cw.config.ContentTypes {
+		if mediaType == t || strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// decide write header and buffered data, compress if large is true and the response is compressible
+func (cw *compressWriter) decide(large bool) error {
+	cw.decided = true
+	if cw.statusCode == 0 {
+		cw.statusCode = http.StatusOK
+	}
+
+	if large && cw.compressible() {
+		header := cw.Header()
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", cw.encoding)
+		// the compressed body is not byte-for-byte the one a strong ETag identifies
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		cw.encoder = cw.pool.Get().(resettableEncoder)
+		cw.encoder.Reset(rawWriter{cw})
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	buf := cw.buf
+	cw.buf = nil
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(buf)
+	} else {
+		_, err = cw.writeRaw(buf)
+	}
+	return err
+}
+
+// close write buffered data and finish compressing
+func (cw *compressWriter) close() error {
+	if !cw.decided {
+		if cw.statusCode == 0 {
+			// nothing written, leave the response to the error handler
+			return nil
+		}
+		if err := cw.decide(false); err != nil {
+			return err
+		}
+	}
+	if cw.encoder == nil {
+		return nil
+	}
+	err := cw.encoder.Close()
+	cw.encoder.Reset(io.Discard)
+	cw.pool.Put(cw.encoder)
+	cw.encoder = nil
+	return err
+}
+
+// Flush send buffered data to the client, buffered data is compressed if compressible regardless of its size
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.statusCode == 0 {
+			cw.statusCode = http.StatusOK
+		}
+		cw.decide(len(cw.buf) > 0)
+	}
+	if cw.encoder != nil {
+		cw.encoder.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack take over the connection, nothing buffered is written
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(cw.ResponseWriter).Hijack()
+}
+
+// Unwrap the original ResponseWriter, for http.ResponseController
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
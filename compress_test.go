@@ -0,0 +1,146 @@
+package goweb
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func testCompressServer() *AppServer {
+	server := NewAppServer(&AppServerConfig{})
+	server.Use(Compress(&CompressConfig{MinSize: 16}))
+	server.GET("/text", func(req *Request, resp *Response, ctx *RequestContext) error {
+		resp.WriteHeader(201)
+		return resp.WriteString(strings.Repeat("hello ", 10))
+	})
+	server.GET("/short", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return resp.WriteString("hi")
+	})
+	server.GET("/png", func(req *Request, resp *Response, ctx *RequestContext) error {
+		resp.Header().Set("Content-Type", "image/png")
+		return resp.WriteString(strings.Repeat("x", 100))
+	})
+	server.GET("/empty", func(req *Request, resp *Response, ctx *RequestContext) error {
+		resp.WriteHeader(204)
+		return nil
+	})
+	server.GET("/error", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return NewHTTPError(404, "not found")
+	})
+	server.prepare()
+	return server
+}
+
+func testCompress(server *AppServer, path string, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(HttpGet, path, nil)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	w := httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, req)
+	return w
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := map[string]string{
+		"":                    "",
+		"gzip, deflate, br":   "gzip",
+		"deflate":             "deflate",
+		"gzip;q=0.5, deflate": "deflate",
+		"*":                   "gzip",
+		"*, gzip;q=0":         "deflate",
+		"br, identity;q=0.5":  "",
+		"GZIP;q=0.8, *;q=0.1": "gzip",
+	}
+	for acceptEncoding, expected := range cases {
+		assert(negotiateEncoding(acceptEncoding, compressEncodings) == expected, "encoding negotiation wrong: "+acceptEncoding)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	server := testCompressServer()
+	expected := strings.Repeat("hello ", 10)
+
+	w := testCompress(server, "/text", "gzip")
+	assert(w.Code == 201 && w.Header().Get("Content-Encoding") == "gzip" && w.Header().Get("Vary") == "Accept-Encoding", "gzip headers wrong")
+	assert(strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain"), "content type should be detected before compressing")
+	gr, err := gzip.NewReader(w.Body)
+	assert(err == nil, "gzip body invalid")
+	body, _ := io.ReadAll(gr)
+	assert(string(body) == expected, "gzip body wrong")
+
+	w = testCompress(server, "/text", "deflate")
+	zr, err := zlib.NewReader(w.Body)
+	assert(err == nil && w.Header().Get("Content-Encoding") == "deflate", "deflate body invalid")
+	body, _ = io.ReadAll(zr)
+	assert(string(body) == expected, "deflate body wrong")
+
+	w = testCompress(server, "/text", "br")
+	assert(w.Header().Get("Content-Encoding") == "" && w.Body.String() == expected && w.Header().Get("Vary") == "Accept-Encoding", "unsupported encoding should not compress")
+
+	w = testCompress(server, "/short", "gzip")
+	assert(w.Code == 200 && w.Header().Get("Content-Encoding") == "" && w.Body.String() == "hi", "short response should not be compressed")
+
+	w = testCompress(server, "/png", "gzip")
+	assert(w.Header().Get("Content-Encoding") == "" && w.Body.Len() == 100, "image should not be compressed")
+
+	w = testCompress(server, "/empty", "gzip")
+	assert(w.Code == 204 && w.Body.Len() == 0 && w.Header().Get("Content-Encoding") == "", "response without body wrong")
+
+	w = testCompress(server, "/error", "gzip")
+	assert(w.Code == 404 && strings.Contains(w.Body.String(), "not found"), "error response wrong")
+}
+
+func TestCompressSizes(t *testing.T) {
+	var logged int64
+	server := NewAppServer(&AppServerConfig{LogHandlerFunc: func(ctx *RequestContext) {
+		logged = ctx.ResponseSize
+	}})
+	server.Use(Compress(&CompressConfig{MinSize: -1}))
+	server.GET("/short", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return resp.WriteString("hi")
+	})
+	server.GET("/long", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return resp.WriteString(strings.Repeat("hello ", 1000))
+	})
+	server.prepare()
+
+	w := testCompress(server, "/short", "gzip")
+	assert(w.Header().Get("Content-Encoding") == "gzip", "negative MinSize should compress any response")
+	assert(logged == int64(w.Body.Len()), "logged size should be the compressed size")
+
+	w = testCompress(server, "/long", "gzip")
+	assert(logged == int64(w.Body.Len()) && logged < 6000, "logged size should be the compressed size")
+
+	w = testCompress(server, "/long", "br")
+	assert(logged == 6000 && w.Body.Len() == 6000, "logged size of uncompressed response wrong")
+}
+
+func TestCompressRanges(t *testing.T) {
+	text := strings.Repeat("hello ", 100)
+	server := NewAppServer(&AppServerConfig{})
+	server.Use(Compress(&CompressConfig{MinSize: 16}))
+	server.StaticFS("/static", fstest.MapFS{"a.txt": {Data: []byte(text)}}, nil)
+	server.GET("/tagged", func(req *Request, resp *Response, ctx *RequestContext) error {
+		resp.Header().Set("ETag", `"v1"`)
+		return resp.WriteString(text)
+	})
+	server.prepare()
+
+	req := httptest.NewRequest(HttpGet, "/static/a.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Range", "bytes=0-4")
+	w := httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, req)
+	assert(w.Code == 206 && w.Header().Get("Content-Encoding") == "" && w.Body.String() == "hello", "partial content should not be compressed")
+
+	w = testCompress(server, "/static/a.txt", "gzip")
+	assert(w.Code == 200 && w.Header().Get("Content-Encoding") == "" && w.Body.String() == text, "response supporting ranges should not be compressed")
+
+	w = testCompress(server, "/tagged", "gzip")
+	assert(w.Header().Get("Content-Encoding") == "gzip" && w.Header().Get("ETag") == `W/"v1"`, "ETag of compressed response should be weak")
+	w = testCompress(server, "/tagged", "br")
+	assert(w.Header().Get("Content-Encoding") == "" && w.Header().Get("ETag") == `"v1"`, "ETag of identity response should be kept")
+}
@@ -1,9 +1,11 @@
 package goweb
 
 const (
-	HttpGet    = "GET"
-	HttpPost   = "POST"
-	HttpPut    = "PUT"
-	HttpDelete = "DELETE"
-	HttpHead   = "HEAD"
+	HttpGet     = "GET"
+	HttpPost    = "POST"
+	HttpPut     = "PUT"
+	HttpDelete  = "DELETE"
+	HttpHead    = "HEAD"
+	HttpPatch   = "PATCH"
+	HttpOptions = "OPTIONS"
 )
@@ -1,11 +1,40 @@
 package goweb
 
 import (
+	"net/http"
 	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/pkg/errors"
 )
 
+var (
+	// HTTP methods served by controller methods named after them, eg: GET => Get
+	conventionMethods = []string{HttpGet, HttpPost, HttpPut, HttpPatch, HttpDelete, HttpHead, HttpOptions}
+
+	// conventions of resource controllers, member routes are registered at pattern + "/:id"
+	resourceConventions = []struct {
+		MethodName string
+		HTTPMethod string
+		Member     bool
+	}{
+		{"List", HttpGet, false},
+		{"Create", HttpPost, false},
+		{"Post", HttpPost, false},
+		{"Get", HttpGet, true},
+		{"Update", HttpPut, true},
+		{"Put", HttpPut, true},
+		{"Patch", HttpPatch, true},
+		{"Delete", HttpDelete, true},
+	}
+
+	typeOfRequest        = reflect.TypeOf((*Request)(nil))
+	typeOfResponse       = reflect.TypeOf((*Response)(nil))
+	typeOfRequestContext = reflect.TypeOf((*RequestContext)(nil))
+	typeOfError          = reflect.TypeOf((*error)(nil)).Elem()
+)
+
 type JSONResponse struct {
 	StatusCode int
 	BodyData   interface{}
@@ -17,56 +46,326 @@ func (r *JSONResponse) Response(resp *Response) {
 	resp.WriteJSON(r.BodyData)
 }
 
-type Controller struct {
-	MethodFuncMap map[string]*reflect.Value
+// kinds of controller method parameters
+const (
+	argRequest = iota
+	argResponse
+	argRequestContext
+	argForm    // a struct filled by Request.FillForm
+	argFormPtr // a pointer to struct filled by Request.FillForm
+)
+
+// ControllerMethod a controller method whose signature has been validated
+// Parameters can be *Request, *Response, *RequestContext in any order,
+// and any number of form structs (or pointers to form structs) which are filled by Request.FillForm.
+// Return values can be one of:
+//      nothing
+//      error
+//      T
+//      (T, error)
+// T is rendered after the method returns: *JSONResponse renders itself, string is written as text/plain,
+// []byte as application/octet-stream, and any other value is encoded as JSON.
+// An error returned by the method is not rendered but passed to the ErrorHandlerFunc.
+type ControllerMethod struct {
+	Name      string
+	fn        reflect.Value
+	argKinds  []int
+	argTypes  []reflect.Type
+	hasResult bool
+	hasError  bool
 }
 
-// Invoke invoke controller method
-func (cm *Controller) Invoke(req *Request, resp *Response, context *RequestContext) error {
-	methodRef, found := cm.MethodFuncMap[req.Req.Method]
-	if !found {
-		resp.WriteHeader(405)
-		return nil
+// NewControllerMethod validate signature of a controller method
+func NewControllerMethod(name string, fn reflect.Value) (*ControllerMethod, error) {
+	if fn.Kind() != reflect.Func {
+		return nil, errors.WithMessage(ErrInvalidMethodSignature, name+" is not a method")
+	}
+	typo := fn.Type()
+	m := &ControllerMethod{
+		Name:     name,
+		fn:       fn,
+		argKinds: make([]int, typo.NumIn()),
+		argTypes: make([]reflect.Type, typo.NumIn()),
 	}
 
-	inValues := make([]reflect.Value, 0)
-	inValues = append(inValues, reflect.ValueOf(req))
-	inValues = append(inValues, reflect.ValueOf(resp))
-	inValues = append(inValues, reflect.ValueOf(context))
+	for i := 0; i < typo.NumIn(); i++ {
+		in := typo.In(i)
+		m.argTypes[i] = in
+		switch {
+		case in == typeOfRequest:
+			m.argKinds[i] = argRequest
+		case in == typeOfResponse:
+			m.argKinds[i] = argResponse
+		case in == typeOfRequestContext:
+			m.argKinds[i] = argRequestContext
+		case in.Kind() == reflect.Struct:
+			m.argKinds[i] = argForm
+		case in.Kind() == reflect.Ptr && in.Elem().Kind() == reflect.Struct:
+			m.argKinds[i] = argFormPtr
+		default:
+			return nil, errors.WithMessage(ErrInvalidMethodSignature, name+": unsupported parameter type "+in.String())
+		}
+		if m.argKinds[i] == argFormPtr {
+			in = in.Elem()
+		}
+		if m.argKinds[i] == argForm || m.argKinds[i] == argFormPtr {
+			if _, err := InspectForm(reflect.New(in).Interface()); err != nil {
+				return nil, errors.WithMessage(err, name+": invalid form "+in.String())
+			}
+		}
+	}
 
-	outValues := (*methodRef).Call(inValues)
-	if outValues == nil || len(outValues) < 1 {
+	switch typo.NumOut() {
+	case 0:
+	case 1:
+		if typo.Out(0) == typeOfError {
+			m.hasError = true
+		} else {
+			m.hasResult = true
+		}
+	case 2:
+		if typo.Out(1) != typeOfError {
+			return nil, errors.WithMessage(ErrInvalidMethodSignature, name+": the second return value must be error")
+		}
+		m.hasResult = true
+		m.hasError = true
+	default:
+		return nil, errors.WithMessage(ErrInvalidMethodSignature, name+": too many return values")
+	}
+
+	return m, nil
+}
+
+// Invoke build arguments, call the method and render its result
+func (m *ControllerMethod) Invoke(req *Request, resp *Response, ctx *RequestContext) error {
+	inValues := make([]reflect.Value, len(m.argKinds))
+	for i, kind := range m.argKinds {
+		switch kind {
+		case argRequest:
+			inValues[i] = reflect.ValueOf(req)
+		case argResponse:
+			inValues[i] = reflect.ValueOf(resp)
+		case argRequestContext:
+			inValues[i] = reflect.ValueOf(ctx)
+		case argForm, argFormPtr:
+			formType := m.argTypes[i]
+			if kind == argFormPtr {
+				formType = formType.Elem()
+			}
+			form := reflect.New(formType)
+			if err := req.FillForm(form.Interface()); err != nil {
+				return err
+			}
+			if kind == argForm {
+				form = form.Elem()
+			}
+			inValues[i] = form
+		}
+	}
+
+	outValues := m.fn.Call(inValues)
+	if m.hasError {
+		if errV := outValues[len(outValues)-1]; !errV.IsNil() {
+			return errV.Interface().(error)
+		}
+	}
+	if !m.hasResult {
 		return nil
 	}
-	if !outValues[0].IsValid() {
+	return renderResult(outValues[0].Interface(), resp)
+}
+
+// renderResult write the value returned by a controller method to the response
+func renderResult(result interface{}, resp *Response) error {
+	switch v := result.(type) {
+	case nil:
 		return nil
+	case error:
+		return v
+	case *JSONResponse:
+		if v != nil {
+			v.Response(resp)
+		}
+		return nil
+	case *TemplateView:
+		return resp.Negotiate(http.StatusOK, v)
+	case string:
+		if resp.Header().Get("Content-Type") == "" {
+			resp.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		return resp.WriteString(v)
+	case []byte:
+		if resp.Header().Get("Content-Type") == "" {
+			resp.Header().Set("Content-Type", "application/octet-stream")
+		}
+		_, err := resp.Write(v)
+		return err
+	default:
+		if resp.Header().Get("Content-Type") == "" {
+			resp.Header().Set("Content-Type", "application/json; charset=utf-8")
+		}
+		return resp.WriteJSON(v)
+	}
+}
+
+type Controller struct {
+	MethodFuncMap map[string]*reflect.Value
+	methods       map[string]*ControllerMethod
+}
+
+// AllowedMethods list HTTP methods the controller serves
+func (cm *Controller) AllowedMethods() []string {
+	methods := make([]string, 0, len(cm.MethodFuncMap)+1)
+	for method := range cm.MethodFuncMap {
+		methods = append(methods, method)
 	}
+	return allowedMethods(methods)
+}
 
-	first := outValues[0].Interface()
-	if j, ok := first.(*JSONResponse); ok {
-		j.Response(resp)
+// Invoke invoke controller method
+func (cm *Controller) Invoke(req *Request, resp *Response, context *RequestContext) error {
+	method, found := cm.methods[req.Req.Method]
+	if !found && req.Req.Method == HttpHead {
+		method, found = cm.methods[HttpGet]
+	}
+	if !found {
+		resp.Header().Set("Allow", strings.Join(cm.AllowedMethods(), ", "))
+		resp.WriteHeader(http.StatusMethodNotAllowed)
 		return nil
 	}
-	// TODO: check return type
-	return nil
+
+	return method.Invoke(req, resp, context)
 }
 
 // WrapController wrap controller obect, return RequestHandlerFunc
+// Signatures of controller methods are validated here, see ControllerMethod for supported signatures.
 func WrapController(ins interface{}, methodMap map[string]string) (RequestHandlerFunc, error) {
 	v := reflect.ValueOf(ins)
 	methodFuncMap := make(map[string]*reflect.Value, 0)
+	methods := make(map[string]*ControllerMethod, 0)
 
 	for httpMethod, methodName := range methodMap {
 		controllerMethod := v.MethodByName(methodName)
 		if !controllerMethod.IsValid() {
 			return nil, errors.WithMessage(ErrMethodNotFound, methodName)
 		}
+		m, err := NewControllerMethod(controllerName(v)+"."+methodName, controllerMethod)
+		if err != nil {
+			return nil, err
+		}
 		methodFuncMap[httpMethod] = &controllerMethod
+		methods[httpMethod] = m
 	}
 
 	mapper := &Controller{
 		MethodFuncMap: methodFuncMap,
+		methods:       methods,
 	}
 
 	return mapper.Invoke, nil
 }
+
+// controllerName name of the controller type, used to describe routes
+func controllerName(v reflect.Value) string {
+	return reflect.Indirect(v).Type().Name()
+}
+
+// WrapControllerMethod wrap a single method of controller object, return RequestHandlerFunc
+func WrapControllerMethod(ins interface{}, methodName string) (RequestHandlerFunc, error) {
+	v := reflect.ValueOf(ins)
+	controllerMethod := v.MethodByName(methodName)
+	if !controllerMethod.IsValid() {
+		return nil, errors.WithMessage(ErrMethodNotFound, methodName)
+	}
+	m, err := NewControllerMethod(controllerName(v)+"."+methodName, controllerMethod)
+	if err != nil {
+		return nil, err
+	}
+	return m.Invoke, nil
+}
+
+// ConventionMethodMap discover controller methods named after HTTP methods, eg: Get => GET, Post => POST
+func ConventionMethodMap(ins interface{}) map[string]string {
+	v := reflect.ValueOf(ins)
+	methodMap := make(map[string]string, 0)
+	for _, httpMethod := range conventionMethods {
+		methodName := httpMethod[:1] + strings.ToLower(httpMethod[1:])
+		if v.MethodByName(methodName).IsValid() {
+			methodMap[httpMethod] = methodName
+		}
+	}
+	return methodMap
+}
+
+// controllerRouters create a router for each HTTP method of methodMap
+// If methodMap is nil, methods are discovered by ConventionMethodMap.
+func controllerRouters(pattern string, ins interface{}, methodMap map[string]string, config *RouterConfig) ([]*Router, error) {
+	if methodMap == nil {
+		methodMap = ConventionMethodMap(ins)
+	}
+	if len(methodMap) == 0 {
+		return nil, errors.WithMessage(ErrMethodNotFound, "no method found in "+controllerName(reflect.ValueOf(ins)))
+	}
+
+	httpMethods := make([]string, 0, len(methodMap))
+	for httpMethod := range methodMap {
+		httpMethods = append(httpMethods, httpMethod)
+	}
+	sort.Strings(httpMethods)
+
+	routers := make([]*Router, 0, len(methodMap))
+	for _, httpMethod := range httpMethods {
+		handlerFunc, err := WrapControllerMethod(ins, methodMap[httpMethod])
+		if err != nil {
+			return nil, err
+		}
+		router := NewMethodRouter(httpMethod, pattern, handlerFunc, config)
+		router.Name = controllerName(reflect.ValueOf(ins)) + "." + methodMap[httpMethod]
+		routers = append(routers, router)
+	}
+	return routers, nil
+}
+
+// resourceRouters create REST style routers by conventions of method names:
+//      List    GET     pattern
+//      Create  POST    pattern (or Post)
+//      Get     GET     pattern/:id
+//      Update  PUT     pattern/:id (or Put)
+//      Patch   PATCH   pattern/:id
+//      Delete  DELETE  pattern/:id
+// A controller defining both Create and Post, or both Update and Put, is rejected with an error naming them.
+func resourceRouters(pattern string, ins interface{}, config *RouterConfig) ([]*Router, error) {
+	v := reflect.ValueOf(ins)
+	memberPattern := strings.TrimSuffix(pattern, "/") + "/:id"
+	routers := make([]*Router, 0)
+	registered := make(map[string]string, 0) // HTTP method and pattern => method name
+	for _, convention := range resourceConventions {
+		if !v.MethodByName(convention.MethodName).IsValid() {
+			continue
+		}
+		route := convention.HTTPMethod + " " + pattern
+		if convention.Member {
+			route = convention.HTTPMethod + " " + memberPattern
+		}
+		if name, found := registered[route]; found {
+			return nil, errors.Errorf("%s.%s and %s.%s both serve %s, define only one of them", controllerName(v), name, controllerName(v), convention.MethodName, route)
+		}
+		registered[route] = convention.MethodName
+
+		handlerFunc, err := WrapControllerMethod(ins, convention.MethodName)
+		if err != nil {
+			return nil, err
+		}
+		p := pattern
+		if convention.Member {
+			p = memberPattern
+		}
+		router := NewMethodRouter(convention.HTTPMethod, p, handlerFunc, config)
+		router.Name = controllerName(v) + "." + convention.MethodName
+		routers = append(routers, router)
+	}
+	if len(routers) == 0 {
+		return nil, errors.WithMessage(ErrMethodNotFound, "no resource method found in "+controllerName(v))
+	}
+	return routers, nil
+}
@@ -0,0 +1,179 @@
+package goweb
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type testItemForm struct {
+	ID   int    `form:"id,required,path"`
+	Name string `form:"name"`
+}
+
+type testItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type itemController struct{}
+
+func (c *itemController) Get(form testItemForm) (*testItem, error) {
+	if form.ID == 0 {
+		return nil, NewHTTPError(404, "no such item")
+	}
+	return &testItem{ID: form.ID, Name: form.Name}, nil
+}
+
+func (c *itemController) Text(ctx *RequestContext, req *Request) string {
+	return ctx.Method + " " + req.PathParam("id")
+}
+
+func (c *itemController) Raw() []byte {
+	return []byte("raw")
+}
+
+func (c *itemController) Delete(resp *Response, form *testItemForm) error {
+	resp.WriteHeader(204)
+	return nil
+}
+
+func (c *itemController) BadParam(id int) error {
+	return nil
+}
+
+func (c *itemController) BadReturn() (string, int) {
+	return "", 0
+}
+
+func testController(method string, methodName string, path string) *httptest.ResponseRecorder {
+	server := NewAppServer(&AppServerConfig{})
+	server.AddController("/items/:id", &itemController{}, map[string]string{method: methodName})
+	server.prepare()
+
+	w := httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestControllerSignatures(t *testing.T) {
+	w := testController(HttpGet, "Get", "/items/12?name=book")
+	assert(w.Code == 200 && strings.TrimSpace(w.Body.String()) == `{"id":12,"name":"book"}`, "(T, error) result wrong")
+	assert(strings.HasPrefix(w.Header().Get("Content-Type"), "application/json"), "JSON content type wrong")
+
+	w = testController(HttpGet, "Get", "/items/0")
+	assert(w.Code == 404, "returned error should be handled")
+
+	w = testController(HttpGet, "Get", "/items/abc")
+	assert(w.Code == 400, "form error should be handled")
+
+	w = testController(HttpGet, "Text", "/items/12")
+	assert(w.Body.String() == "GET 12" && strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain"), "string result wrong")
+
+	w = testController(HttpGet, "Raw", "/items/12")
+	assert(w.Body.String() == "raw" && w.Header().Get("Content-Type") == "application/octet-stream", "[]byte result wrong")
+
+	w = testController(HttpDelete, "Delete", "/items/12")
+	assert(w.Code == 204, "pointer form param wrong")
+}
+
+func TestControllerInvalidSignatures(t *testing.T) {
+	_, err := WrapController(&itemController{}, map[string]string{HttpGet: "BadParam"})
+	assert(errors.Cause(err) == ErrInvalidMethodSignature, "unsupported param should be rejected")
+
+	_, err = WrapController(&itemController{}, map[string]string{HttpGet: "BadReturn"})
+	assert(errors.Cause(err) == ErrInvalidMethodSignature, "unsupported return values should be rejected")
+
+	_, err = WrapController(&itemController{}, map[string]string{HttpGet: "Missing"})
+	assert(errors.Cause(err) == ErrMethodNotFound, "missing method should be rejected")
+}
+
+type conventionController struct{}
+
+func (c *conventionController) Get() string {
+	return "get"
+}
+
+func (c *conventionController) Post() string {
+	return "post"
+}
+
+type resourceController struct{}
+
+func (c *resourceController) List() string {
+	return "list"
+}
+
+func (c *resourceController) Create() string {
+	return "create"
+}
+
+func (c *resourceController) Get(req *Request) string {
+	return "get " + req.PathParam("id")
+}
+
+func (c *resourceController) Delete(req *Request) string {
+	return "delete " + req.PathParam("id")
+}
+
+type ambiguousResourceController struct{}
+
+func (c *ambiguousResourceController) Create() string {
+	return "create"
+}
+
+func (c *ambiguousResourceController) Post() string {
+	return "post"
+}
+
+func TestConventionController(t *testing.T) {
+	server := NewAppServer(&AppServerConfig{})
+	server.AddController("/convention", &conventionController{}, nil)
+	server.AddResource("/items", &resourceController{})
+	server.prepare()
+
+	cases := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+	}{
+		{HttpGet, "/convention", 200, "get"},
+		{HttpPost, "/convention", 200, "post"},
+		{HttpDelete, "/convention", 405, ""},
+		{HttpGet, "/items", 200, "list"},
+		{HttpPost, "/items", 200, "create"},
+		{HttpGet, "/items/12", 200, "get 12"},
+		{HttpDelete, "/items/12", 200, "delete 12"},
+		{HttpPut, "/items/12", 405, ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		server.ServeMux.ServeHTTP(w, req)
+		assert(w.Code == c.code && (c.body == "" || w.Body.String() == c.body), "convention route wrong: "+c.method+" "+c.path)
+	}
+
+	routes := make([]string, 0)
+	for _, info := range server.Routes() {
+		routes = append(routes, info.Method+" "+info.Pattern+" "+info.Handler)
+	}
+	expected := []string{
+		"GET /convention conventionController.Get",
+		"POST /convention conventionController.Post",
+		"GET /items resourceController.List",
+		"POST /items resourceController.Create",
+		"DELETE /items/:id resourceController.Delete",
+		"GET /items/:id resourceController.Get",
+	}
+	assert(strings.Join(routes, "\n") == strings.Join(expected, "\n"), "route listing wrong")
+
+	_, err := resourceRouters("/empty", &struct{}{}, nil)
+	assert(errors.Cause(err) == ErrMethodNotFound, "empty resource should be rejected")
+
+	_, err = resourceRouters("/ambiguous", &ambiguousResourceController{}, nil)
+	assert(err != nil && strings.Contains(err.Error(), "ambiguousResourceController.Create and ambiguousResourceController.Post both serve POST /ambiguous"), "methods serving the same route should be rejected")
+}
@@ -2,11 +2,59 @@ package goweb
 
 import (
 	"errors"
+	"fmt"
+	"runtime/debug"
 )
 
 var (
 	// ErrUnknowContentType on header "Content-Type" present.
 	ErrUnknowContentType = errors.New("Content-Type header not found")
 
+	// ErrUnsupportedContentType the request body can not be decoded
+	ErrUnsupportedContentType = errors.New("Content-Type not supported")
+
 	ErrMethodNotFound = errors.New("HTTP")
+
+	// ErrInvalidMethodSignature a controller method has unsupported parameters or return values
+	ErrInvalidMethodSignature = errors.New("invalid controller method signature")
+
+	// ErrRouteConflict a router pattern conflicts with a registered one
+	ErrRouteConflict = errors.New("route conflict")
+
+	// ErrInvalidPattern a router pattern can not be parsed
+	ErrInvalidPattern = errors.New("invalid route pattern")
+
+	// ErrNotAcceptable none of the media types the response can be encoded in is acceptable by the client
+	ErrNotAcceptable = errors.New("not acceptable")
+
+	// ErrTemplateNotFound no template of the given name is loaded
+	ErrTemplateNotFound = errors.New("template not found")
+
+	// ErrNoTemplateEngine templates are rendered by name without a TemplateEngine configured
+	ErrNoTemplateEngine = errors.New("template engine not configured")
 )
+
+// PanicError a panic recovered while handling a request
+type PanicError struct {
+	Value interface{} // the value passed to panic
+	Stack []byte      // stack trace of the goroutine where panic occurred
+}
+
+func newPanicError(value interface{}) *PanicError {
+	return &PanicError{
+		Value: value,
+		Stack: debug.Stack(),
+	}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap return the panic value if it is an error
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
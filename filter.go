@@ -0,0 +1,66 @@
+package goweb
+
+// Filter a request filter with before and after phases, either phase may be nil
+// Filters of a RouterHub run before routing, Before may rewrite the path with Request.RewritePath to change the matched router.
+// Filters of a Router run after params are parsed, right before the handler.
+// A Before returning an error or finishing the response stops the request, the handler and later filters are skipped.
+// After runs in reverse order with the error returned by the handler or a later filter, for every filter whose Before has run,
+// the error it returns is passed on, so it may replace or clear the error.
+type Filter struct {
+	Before BeforeFilterFunc
+	After  AfterFilterFunc
+}
+
+// BeforeFilter create a filter with only the before phase
+func BeforeFilter(f BeforeFilterFunc) *Filter {
+	return &Filter{Before: f}
+}
+
+// AfterFilter create a filter with only the after phase
+func AfterFilter(f AfterFilterFunc) *Filter {
+	return &Filter{After: f}
+}
+
+// requestFilterAdapter run a RequestFilter as the before phase of a filter
+func requestFilterAdapter(filter RequestFilter) *Filter {
+	return BeforeFilter(func(req *Request, resp *Response, ctx *RequestContext) error {
+		return filter.FilterRequest(resp, ctx)
+	})
+}
+
+// withFilters wrap next with filters, next is returned as is if there is no filter
+func withFilters(filters []*Filter, next RequestHandlerFunc) RequestHandlerFunc {
+	if len(filters) == 0 {
+		return next
+	}
+	return func(req *Request, resp *Response, ctx *RequestContext) error {
+		return applyFilters(filters, next, req, resp, ctx)
+	}
+}
+
+// applyFilters run before phases of filters, then next if not stopped, then after phases in reverse order
+func applyFilters(filters []*Filter, next RequestHandlerFunc, req *Request, resp *Response, ctx *RequestContext) error {
+	var err error
+	entered := 0
+	stopped := false
+	for _, filter := range filters {
+		entered++
+		if filter.Before == nil {
+			continue
+		}
+		if err = filter.Before(req, resp, ctx); err != nil || ctx.Finished() {
+			stopped = true
+			break
+		}
+	}
+	if !stopped {
+		err = next(req, resp, ctx)
+	}
+
+	for i := entered - 1; i >= 0; i-- {
+		if filters[i].After != nil {
+			err = filters[i].After(req, resp, ctx, err)
+		}
+	}
+	return err
+}
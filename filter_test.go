@@ -0,0 +1,145 @@
+package goweb
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func traceFilter(trace *[]string, name string) *Filter {
+	return &Filter{
+		Before: func(req *Request, resp *Response, ctx *RequestContext) error {
+			*trace = append(*trace, ">"+name)
+			return nil
+		},
+		After: func(req *Request, resp *Response, ctx *RequestContext, err error) error {
+			if err != nil {
+				*trace = append(*trace, "!"+name)
+			}
+			*trace = append(*trace, "<"+name)
+			return err
+		},
+	}
+}
+
+func TestFilterOrder(t *testing.T) {
+	trace := make([]string, 0)
+	var handledErr error
+	server := NewAppServer(&AppServerConfig{
+		ErrorHandlerFunc: func(err error, resp *Response, ctx *RequestContext) {
+			handledErr = err
+		},
+	})
+
+	errFailed := errors.New("failed")
+	hub := NewRouterHub("/filter/")
+	hub.AddFilter(traceFilter(&trace, "h1"), traceFilter(&trace, "h2"))
+	hub.AddRequestFilter(NewRequestFilter(func(resp *Response, ctx *RequestContext) error {
+		trace = append(trace, "legacy")
+		return nil
+	}))
+	router := NewMethodRouter(HttpGet, "/filter/:id", func(req *Request, resp *Response, ctx *RequestContext) error {
+		trace = append(trace, "handler "+req.PathParam("id"))
+		return errFailed
+	}, &RouterConfig{Filters: []*Filter{traceFilter(&trace, "c")}})
+	router.AddFilter(traceFilter(&trace, "r"))
+	hub.AddRouter(router)
+	server.AddHub(hub)
+	server.prepare()
+
+	server.ServeMux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(HttpGet, "/filter/7", nil))
+	expected := ">h1 >h2 legacy >c >r handler 7 !r <r !c <c !h2 <h2 !h1 <h1"
+	assert(strings.Join(trace, " ") == expected, "filter order wrong: "+strings.Join(trace, " "))
+	assert(handledErr == errFailed, "handler error should be passed on")
+}
+
+func TestFilterStopAndRecover(t *testing.T) {
+	var handledErr error
+	server := NewAppServer(&AppServerConfig{
+		ErrorHandlerFunc: func(err error, resp *Response, ctx *RequestContext) {
+			handledErr = err
+			DefaultErrorHandler(err, resp, ctx)
+		},
+	})
+	errDenied := NewHTTPError(403, "denied")
+	afterCalled := false
+	hub := NewRouterHub("/guard/")
+	hub.AddFilter(AfterFilter(func(req *Request, resp *Response, ctx *RequestContext, err error) error {
+		afterCalled = true
+		return err
+	}), BeforeFilter(func(req *Request, resp *Response, ctx *RequestContext) error {
+		if req.Header("Authorization") == "" {
+			return errDenied
+		}
+		return nil
+	}))
+	hub.GET("/guard/secret", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return errors.New("boom")
+	})
+	hub.GET("/guard/ok", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return resp.WriteString("ok")
+	})
+	server.AddHub(hub)
+
+	recovering := NewRouterHub("/recover/")
+	recovering.AddFilter(AfterFilter(func(req *Request, resp *Response, ctx *RequestContext, err error) error {
+		if err != nil && !ctx.Finished() {
+			resp.WriteString("recovered: " + err.Error())
+		}
+		return nil
+	}))
+	recovering.GET("/recover/x", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return errors.New("boom")
+	})
+	server.AddHub(recovering)
+	server.prepare()
+
+	w := httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, httptest.NewRequest(HttpGet, "/guard/secret", nil))
+	assert(w.Code == 403 && handledErr == errDenied && afterCalled, "before filter should stop the request")
+
+	req := httptest.NewRequest(HttpGet, "/guard/ok", nil)
+	req.Header.Set("Authorization", "token")
+	w = httptest.NewRecorder()
+	handledErr = nil
+	server.ServeMux.ServeHTTP(w, req)
+	assert(w.Code == 200 && w.Body.String() == "ok" && handledErr == nil, "allowed request wrong")
+
+	w = httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, httptest.NewRequest(HttpGet, "/recover/x", nil))
+	assert(w.Code == 200 && w.Body.String() == "recovered: boom" && handledErr == nil, "after filter should replace the error")
+}
+
+func TestFilterRewritePath(t *testing.T) {
+	server := NewAppServer(&AppServerConfig{})
+	hub := NewRouterHub("/api/")
+	hub.AddFilter(BeforeFilter(func(req *Request, resp *Response, ctx *RequestContext) error {
+		if strings.HasPrefix(req.URL.Path, "/api/v1/") {
+			req.RewritePath("/api/v2/" + strings.TrimPrefix(req.URL.Path, "/api/v1/"))
+		}
+		return nil
+	}))
+	hub.GET("/api/v2/users/:id", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return resp.WriteString("v2 user " + req.PathParam("id") + " " + req.Req.URL.Path)
+	})
+	hub.HandleMethod(HttpPost, "/api/v1/notes", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return resp.WriteString("v1 note")
+	})
+	hub.AddRouter(NewMethodRouter(HttpPost, "/api/v2/notes", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return resp.WriteString("v2 note " + req.Param("text"))
+	}, &RouterConfig{MaxBodySize: 10}))
+	server.AddHub(hub)
+	server.prepare()
+
+	w := httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, httptest.NewRequest(HttpGet, "/api/v1/users/3", nil))
+	assert(w.Code == 200 && w.Body.String() == "v2 user 3 /api/v2/users/3", "rewritten path wrong: "+w.Body.String())
+
+	req := httptest.NewRequest(HttpPost, "/api/v1/notes", strings.NewReader("text="+strings.Repeat("x", 20)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, req)
+	assert(w.Code == 413, "limits of the router matched after rewriting should apply: "+strconv.Itoa(w.Code))
+}
@@ -1,8 +1,12 @@
 package goweb
 
 import (
+	"mime/multipart"
 	"reflect"
 	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
 )
 
 const (
@@ -14,15 +18,21 @@ const (
 	FormErrTypeCannotCast = 3
 	// FormErrNotAValidForm form validation failed
 	FormErrNotAValidForm = 4
+	// FormErrInvalidBody request body can not be decoded
+	FormErrInvalidBody = 5
+	// FormErrRuleViolation field value violates a rule in the form tag
+	FormErrRuleViolation = 6
 )
 
 var (
-	formCache     = make(map[string]*FormMeta, 0)
+	formCache     sync.Map // reflect.Type => *FormMeta
 	formErrMsgMap = map[int]string{
 		FormErrMissingRequired: "Missing Required Field",
 		FormErrNotAForm:        "Form must be a struct",
 		FormErrTypeCannotCast:  "Can not cast to type",
 		FormErrNotAValidForm:   "Form validation failed",
+		FormErrInvalidBody:     "Invalid request body",
+		FormErrRuleViolation:   "Invalid field value",
 	}
 )
 
@@ -46,26 +56,64 @@ func (fe *FormError) Error() string {
 	if !found {
 		msg = "Form Error"
 	}
+	if fe.Type == FormErrRuleViolation && fe.Err != nil {
+		return msg + ":" + fe.FieldName + " " + fe.Err.Error()
+	}
 	return msg + ":" + fe.FieldName
 }
 
+// FormErrors errors of every failing field of a form
+type FormErrors []*FormError
+
+func (fes FormErrors) Error() string {
+	msgs := make([]string, 0, len(fes))
+	for _, fe := range fes {
+		msgs = append(msgs, fe.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap return errors of fields, so errors.As can find a FormError in it
+func (fes FormErrors) Unwrap() []error {
+	errs := make([]error, 0, len(fes))
+	for _, fe := range fes {
+		errs = append(errs, fe)
+	}
+	return errs
+}
+
 // FormMeta meta info of a Form Object
+// FieldNames keeps names of form fields in the order they are declared.
 type FormMeta struct {
 	Type           *reflect.Type
 	RequiredFields []string
+	FieldNames     []string
 	FieldMap       map[string]*FieldConfig
 }
 
 // FieldConfig Represent settings of a form field, decoded from the struct field tag named form
 // For Example:
 // type CreatePostForm struct {
-//      title   string  `form:"title,required"`
+//      title   string  `form:"title,required,min=2,max=64"`
 //      user_id int     `form:"X-User-Id,required,header"`
 //      content string  `form:"content"`
+//      status  string  `form:"status,oneof=draft|published,default=draft"`
 // }
 // The first value of form tag is the name of parameter.
 // header, cookie, path indecate where should the parameter be fetched. Parameter fetched from QueryString, body forms by default.
 // required means the form field value must be a none empty string
+// default=V gives the value used when the parameter is absent.
+// layout=L gives the layout of time.Time values, RFC 3339 by default.
+// split=S tells how slices are encoded, values of all repeated params are collected and then split by S:
+// comma (default) splits values by commas, pipe by |, repeat keeps each value as an element.
+// Arrays and repeated elements of a JSON or XML body are never split.
+// Other values are validation rules, see FieldRule. Rules are checked only if the parameter presents.
+//
+// Pointer fields are left nil if the parameter is absent.
+// Embedded structs without form tag are flattened, their fields are treated as fields of the outer form.
+// Struct fields are nested forms, their fields are addressed as name.field (or name[field]),
+// slices of structs as name[0].field, maps with string keys as name[key] (or name.key).
+// Fields of type *multipart.FileHeader or []*multipart.FileHeader are bound to files uploaded by multipart forms.
 type FieldConfig struct {
 	ParamName  string
 	Type       *reflect.Type
@@ -73,14 +121,54 @@ type FieldConfig struct {
 	FromCookie bool
 	FromPath   bool
 	IsRequired bool
+	HasDefault bool
+	Default    string
+	Layout     string
+	Split      string
+	Rules      []*FieldRule
+	Kind       int          // FieldScalar, FieldStruct, FieldStructSlice, FieldMap or FieldFile
+	Index      []int        // index sequence of the field, for embedded fields it starts from the outer form
+	IsPointer  bool         // field is a pointer, ElemType is the type pointed to
+	ElemType   reflect.Type // type of scalar or struct value, element type of struct slices and maps
+	Nested     *FormMeta    // meta info of nested forms
+	field      func(form reflect.Value) reflect.Value
 }
 
-func decodeFormTag(tagValue string) *FieldConfig {
+// encodings of slice fields
+const (
+	// SplitComma values are comma separated
+	SplitComma = "comma"
+	// SplitPipe values are separated by |
+	SplitPipe = "pipe"
+	// SplitRepeat each repeated param is a value
+	SplitRepeat = "repeat"
+)
+
+// kinds of form fields
+const (
+	// FieldScalar a field holds a single value or a slice of values
+	FieldScalar = iota
+	// FieldStruct a nested form
+	FieldStruct
+	// FieldStructSlice a slice of nested forms
+	FieldStructSlice
+	// FieldMap a map with string keys
+	FieldMap
+	// FieldFile an uploaded file (*multipart.FileHeader) or files ([]*multipart.FileHeader)
+	FieldFile
+)
+
+var (
+	typeFileHeader  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	typeFileHeaders = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+func decodeFormTag(tagValue string) (*FieldConfig, error) {
 	if tagValue == "" {
-		return nil
+		return nil, nil
 	}
 
-	parts := strings.Split(tagValue, ",")
+	parts := splitFormTag(tagValue)
 	c := &FieldConfig{
 		ParamName:  parts[0],
 		FromHeader: false,
@@ -97,18 +185,130 @@ func decodeFormTag(tagValue string) *FieldConfig {
 			c.FromCookie = true
 		} else if name == "path" {
 			c.FromPath = true
+		} else if strings.HasPrefix(name, "default=") {
+			c.HasDefault = true
+			c.Default = name[len("default="):]
+		} else if strings.HasPrefix(name, "split=") {
+			c.Split = name[len("split="):]
+			if c.Split != SplitComma && c.Split != SplitPipe && c.Split != SplitRepeat {
+				return nil, errors.New("unknown split " + c.Split)
+			}
+		} else if strings.HasPrefix(name, "layout=") {
+			c.Layout = name[len("layout="):]
+		} else if name != "" {
+			arg := ""
+			if i := strings.Index(name, "="); i >= 0 {
+				name, arg = name[:i], name[i+1:]
+			}
+			rule, err := newFieldRule(name, arg)
+			if err != nil {
+				return nil, err
+			}
+			c.Rules = append(c.Rules, rule)
+		}
+	}
+
+	return c, nil
+}
+
+// isNestedFormType test if values of type t are filled as nested forms
+// Structs cast from a string as a whole, such as time.Time, are not nested forms.
+func isNestedFormType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && !hasStringCaster(t)
+}
+
+// inspectFieldKind detect kind and element type of a form field
+func inspectFieldKind(fieldConf *FieldConfig, t reflect.Type, inspecting map[reflect.Type]*FormMeta) error {
+	if t == typeFileHeader || t == typeFileHeaders {
+		if fieldConf.FromHeader || fieldConf.FromCookie || fieldConf.FromPath {
+			return errors.New("files can only be fetched from multipart forms")
+		}
+		fieldConf.Kind = FieldFile
+		fieldConf.ElemType = t
+		return nil
+	}
+	if t.Kind() == reflect.Ptr {
+		fieldConf.IsPointer = true
+		t = t.Elem()
+	}
+	fieldConf.ElemType = t
+
+	var nestedType reflect.Type
+	switch {
+	case isNestedFormType(t):
+		fieldConf.Kind = FieldStruct
+		nestedType = t
+	case t.Kind() == reflect.Slice && isNestedFormType(indirectType(t.Elem())):
+		fieldConf.Kind = FieldStructSlice
+		fieldConf.ElemType = t.Elem()
+		nestedType = indirectType(t.Elem())
+	case t.Kind() == reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return errors.New("map key must be string")
+		}
+		fieldConf.Kind = FieldMap
+		fieldConf.ElemType = t.Elem()
+	default:
+		fieldConf.Kind = FieldScalar
+	}
+
+	if nestedType != nil {
+		if fieldConf.FromHeader || fieldConf.FromCookie || fieldConf.FromPath {
+			return errors.New("nested form can only be fetched from params")
+		}
+		nested, err := inspectFormType(nestedType, inspecting)
+		if err != nil {
+			return err
 		}
+		fieldConf.Nested = nested
 	}
+	return nil
+}
 
-	return c
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
 }
 
 // InspectForm Get meta info of a form object
+// Meta info is cached by the type of the form, it is safe to call InspectForm concurrently.
 func InspectForm(m interface{}) (*FormMeta, error) {
 	typo := reflect.Indirect(reflect.ValueOf(m)).Type()
-	fullName := typo.PkgPath() + "." + typo.Name()
+	if found, exists := formCache.Load(typo); exists {
+		return found.(*FormMeta), nil
+	}
+
+	inspecting := make(map[reflect.Type]*FormMeta, 0)
+	meta, err := inspectFormType(typo, inspecting)
+	if err != nil {
+		return nil, err
+	}
+	// nested forms are cached with the form, another goroutine may have inspected the same type meanwhile
+	for t, inspected := range inspecting {
+		formCache.LoadOrStore(t, inspected)
+	}
+	found, _ := formCache.LoadOrStore(typo, meta)
+	return found.(*FormMeta), nil
+}
+
+// RegisterForms inspect forms at startup, it panics if any of them is not a valid form
+// Forms used as params of controller methods are registered with the controller.
+func RegisterForms(forms ...interface{}) {
+	for _, form := range forms {
+		if _, err := InspectForm(form); err != nil {
+			panic(errors.WithMessage(err, reflect.TypeOf(form).String()))
+		}
+	}
+}
 
-	if found, exsits := formCache[fullName]; exsits {
+// inspectFormType inspect a form type, forms being inspected are kept in inspecting so forms can refer to themselves through pointers
+func inspectFormType(typo reflect.Type, inspecting map[reflect.Type]*FormMeta) (*FormMeta, error) {
+	if found, exists := formCache.Load(typo); exists {
+		return found.(*FormMeta), nil
+	}
+	if found, exists := inspecting[typo]; exists {
 		return found, nil
 	}
 
@@ -116,26 +316,57 @@ func InspectForm(m interface{}) (*FormMeta, error) {
 		return nil, newFormError(FormErrNotAForm, "", nil)
 	}
 
-	fieldMap := make(map[string]*FieldConfig, 0)
-	requiredFields := make([]string, 0)
+	meta := &FormMeta{
+		Type:           &typo,
+		FieldMap:       make(map[string]*FieldConfig, 0),
+		RequiredFields: make([]string, 0),
+		FieldNames:     make([]string, 0),
+	}
+	inspecting[typo] = meta
+	if err := meta.addFields(typo, nil, "", inspecting); err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
 
+// addFields add fields of struct type typo, index and namePrefix locate embedded structs in the form
+func (meta *FormMeta) addFields(typo reflect.Type, index []int, namePrefix string, inspecting map[reflect.Type]*FormMeta) error {
 	for i := 0; i < typo.NumField(); i++ {
 		structField := typo.Field(i)
-		fieldConf := decodeFormTag(structField.Tag.Get("form"))
-		if fieldConf != nil {
-			fieldConf.Type = &structField.Type
-			if fieldConf.IsRequired {
-				requiredFields = append(requiredFields, structField.Name)
+		fieldIndex := append(append([]int{}, index...), i)
+		tag := structField.Tag.Get("form")
+
+		if structField.Anonymous && tag == "" {
+			embeddedType := indirectType(structField.Type)
+			if embeddedType.Kind() == reflect.Struct {
+				if err := meta.addFields(embeddedType, fieldIndex, namePrefix+structField.Name+".", inspecting); err != nil {
+					return err
+				}
 			}
-			fieldMap[structField.Name] = fieldConf
+			continue
 		}
-	}
 
-	formCache[fullName] = &FormMeta{
-		Type:           &typo,
-		FieldMap:       fieldMap,
-		RequiredFields: requiredFields,
-	}
+		fieldConf, err := decodeFormTag(tag)
+		if err != nil {
+			return errors.WithMessage(err, typo.Name()+"."+structField.Name)
+		}
+		if fieldConf == nil {
+			continue
+		}
+		fieldConf.Type = &structField.Type
+		fieldConf.Index = fieldIndex
+		fieldConf.field = newFieldAccessor(*meta.Type, fieldIndex)
+		if err := inspectFieldKind(fieldConf, structField.Type, inspecting); err != nil {
+			return errors.WithMessage(err, typo.Name()+"."+structField.Name)
+		}
 
-	return formCache[fullName], nil
+		name := namePrefix + structField.Name
+		if fieldConf.IsRequired {
+			meta.RequiredFields = append(meta.RequiredFields, name)
+		}
+		meta.FieldMap[name] = fieldConf
+		meta.FieldNames = append(meta.FieldNames, name)
+	}
+	return nil
 }
@@ -0,0 +1,502 @@
+package goweb
+
+import (
+	"mime/multipart"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// formSource params used to fill a form, fetched on demand
+// Names are normalized to dotted names, user[address][city] is the same as user.address.city,
+// indexes of slices are kept as items[0].sku.
+type formSource struct {
+	r         *Request
+	bodyOnly  bool
+	values    url.Values
+	bodyNames map[string]bool // params decoded from a structured body
+}
+
+// load fetch params from the body (and QueryString if not bodyOnly)
+func (src *formSource) load() error {
+	if src.values != nil {
+		return nil
+	}
+
+	var raw url.Values
+	if !src.r.HasStructuredBody() {
+		if src.bodyOnly {
+			return ErrUnsupportedContentType
+		}
+		if err := src.r.ParseParam(); err != nil {
+			return err
+		}
+		if src.r.Req.Form == nil {
+			src.r.Req.ParseForm()
+		}
+		raw = src.r.Req.Form
+	} else {
+		body, err := src.r.BodyValues()
+		if err != nil {
+			return err
+		}
+		raw = body
+		src.bodyNames = make(map[string]bool, len(body))
+		for k := range body {
+			src.bodyNames[normalizeParamName(k)] = true
+		}
+		if !src.bodyOnly {
+			// QueryString is used if a param is not in body
+			merged := make(url.Values, len(body))
+			for k, vs := range src.r.URL.Query() {
+				merged[k] = vs
+			}
+			for k, vs := range body {
+				merged[k] = vs
+			}
+			raw = merged
+		}
+	}
+
+	src.values = make(url.Values, len(raw))
+	for k, vs := range raw {
+		name := normalizeParamName(k)
+		src.values[name] = append(src.values[name], vs...)
+	}
+	return nil
+}
+
+// fromBody test if a param is decoded from a structured body
+// Arrays in a JSON or XML body are already repeated values, they are never split.
+func (src *formSource) fromBody(name string) bool {
+	return src.bodyNames[name]
+}
+
+// get values of a param
+func (src *formSource) get(name string) ([]string, error) {
+	if err := src.load(); err != nil {
+		return nil, err
+	}
+	return src.values[name], nil
+}
+
+// keysWithPrefix get names of params starting with prefix
+func (src *formSource) keysWithPrefix(prefix string) ([]string, error) {
+	if err := src.load(); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0)
+	for k := range src.values {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// normalizeParamName convert bracketed names to dotted names, numeric indexes are kept
+func normalizeParamName(name string) string {
+	if !strings.Contains(name, "[") {
+		return name
+	}
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(name, '[')
+		end := strings.IndexByte(name, ']')
+		if start < 0 || end < start {
+			b.WriteString(name)
+			break
+		}
+		b.WriteString(name[:start])
+		key := name[start+1 : end]
+		if _, err := strconv.Atoi(key); err == nil {
+			b.WriteString(name[start : end+1])
+		} else if key != "" {
+			b.WriteString("." + key)
+		}
+		name = name[end+1:]
+	}
+	return b.String()
+}
+
+// joinParamName name of a field in a nested form
+func joinParamName(prefix string, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// newFieldAccessor precompute how to get a field by index sequence from a form of type typo
+// Nil embedded pointers on the way are allocated.
+func newFieldAccessor(typo reflect.Type, index []int) func(form reflect.Value) reflect.Value {
+	if len(index) == 1 {
+		i := index[0]
+		return func(form reflect.Value) reflect.Value {
+			return form.Field(i)
+		}
+	}
+
+	hasPointer := false
+	t := typo
+	for _, x := range index[:len(index)-1] {
+		t = t.Field(x).Type
+		if t.Kind() == reflect.Ptr {
+			hasPointer = true
+			t = t.Elem()
+		}
+	}
+	if !hasPointer {
+		return func(form reflect.Value) reflect.Value {
+			return form.FieldByIndex(index)
+		}
+	}
+
+	return func(form reflect.Value) reflect.Value {
+		v := form
+		for i, x := range index {
+			if i > 0 && v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					v.Set(reflect.New(v.Type().Elem()))
+				}
+				v = v.Elem()
+			}
+			v = v.Field(x)
+		}
+		return v
+	}
+}
+
+// paramValues get all values of a param, and how values of a slice field are split
+// Values from a structured body are repeated values, the split tag applies to other sources.
+// Empty values are dropped, except empty elements of body arrays.
+func (r *Request) paramValues(fieldConf *FieldConfig, name string, src *formSource) ([]string, string, error) {
+	var vs []string
+	split := fieldConf.Split
+	fromBody := false
+	if fieldConf.FromPath {
+		vs = []string{r.PathParam(fieldConf.ParamName)}
+	} else if fieldConf.FromHeader {
+		vs = r.Req.Header.Values(fieldConf.ParamName)
+	} else if fieldConf.FromCookie {
+		vs = []string{r.Cookie(fieldConf.ParamName)}
+	} else {
+		var err error
+		if vs, err = src.get(name); err != nil {
+			return nil, "", err
+		}
+		if fromBody = src.fromBody(name); fromBody {
+			split = SplitRepeat
+		}
+	}
+
+	if fromBody {
+		// null elements of body arrays are kept as zero values, unless all values are empty
+		for _, v := range vs {
+			if v != "" {
+				return vs, split, nil
+			}
+		}
+	}
+	values := make([]string, 0, len(vs))
+	for _, v := range vs {
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	return values, split, nil
+}
+
+// isMultiValueType test if values of type t are collected from all values of a param
+func isMultiValueType(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && !hasStringCaster(t)
+}
+
+// splitValues split values of a slice field according to the split tag, spaces around parts are trimmed
+func splitValues(vs []string, split string) []string {
+	sep := ","
+	switch split {
+	case SplitRepeat:
+		return vs
+	case SplitPipe:
+		sep = "|"
+	}
+
+	parts := make([]string, 0, len(vs))
+	for _, v := range vs {
+		for _, part := range strings.Split(v, sep) {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+	}
+	return parts
+}
+
+// castSlice cast values to a slice of type t, elements failed to cast are reported with their indexes
+func castSlice(vs []string, t reflect.Type, fieldConf *FieldConfig, name string, fieldErrors *FormErrors) (reflect.Value, bool) {
+	list := reflect.MakeSlice(t, 0, len(vs))
+	ok := true
+	for i, v := range vs {
+		item, err := castString(v, t.Elem(), fieldConf.Layout)
+		if err != nil {
+			*fieldErrors = append(*fieldErrors, &FormError{Type: FormErrTypeCannotCast, FieldName: name + "[" + strconv.Itoa(i) + "]", Err: err})
+			ok = false
+			continue
+		}
+		list = reflect.Append(list, item)
+	}
+	return list, ok
+}
+
+// checkRules check rules of a field, the first violation is reported
+func checkRules(fieldConf *FieldConfig, name string, fieldV reflect.Value, fieldErrors *FormErrors) {
+	fieldV = reflect.Indirect(fieldV)
+	for _, rule := range fieldConf.Rules {
+		if err := rule.Check(fieldV); err != nil {
+			*fieldErrors = append(*fieldErrors, &FormError{Type: FormErrRuleViolation, FieldName: name, Err: err})
+			return
+		}
+	}
+}
+
+// fillStruct fill fields of form, params of nested forms are prefixed with prefix
+// Field errors are collected into fieldErrors, other errors are returned.
+func (r *Request) fillStruct(form reflect.Value, meta *FormMeta, prefix string, src *formSource, fieldErrors *FormErrors) error {
+	for _, fieldName := range meta.FieldNames {
+		fieldConf := meta.FieldMap[fieldName]
+		fieldV := fieldConf.field(form)
+		name := fieldConf.ParamName
+		if !fieldConf.FromPath && !fieldConf.FromHeader && !fieldConf.FromCookie {
+			name = joinParamName(prefix, fieldConf.ParamName)
+		}
+
+		var err error
+		switch fieldConf.Kind {
+		case FieldStruct:
+			err = r.fillNested(fieldConf, name, fieldV, src, fieldErrors)
+		case FieldStructSlice:
+			err = r.fillNestedSlice(fieldConf, name, fieldV, src, fieldErrors)
+		case FieldMap:
+			err = r.fillMap(fieldConf, name, fieldV, src, fieldErrors)
+		case FieldFile:
+			err = r.fillFile(fieldConf, name, fieldV, fieldErrors)
+		default:
+			err = r.fillScalar(fieldConf, name, fieldV, src, fieldErrors)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fillScalar fill a field holding a value or a slice of values
+func (r *Request) fillScalar(fieldConf *FieldConfig, name string, fieldV reflect.Value, src *formSource, fieldErrors *FormErrors) error {
+	vs, split, err := r.paramValues(fieldConf, name, src)
+	if err != nil {
+		return err
+	}
+
+	if len(vs) == 0 && fieldConf.HasDefault {
+		vs = []string{fieldConf.Default}
+	}
+
+	isMultiValue := isMultiValueType(fieldConf.ElemType)
+	if isMultiValue {
+		vs = splitValues(vs, split)
+	}
+
+	if len(vs) == 0 {
+		if fieldConf.IsRequired {
+			*fieldErrors = append(*fieldErrors, &FormError{Type: FormErrMissingRequired, FieldName: name})
+		}
+		// absent optional params leave fields zero and pointers nil
+		return nil
+	}
+
+	var vv reflect.Value
+	if isMultiValue {
+		var ok bool
+		if vv, ok = castSlice(vs, fieldConf.ElemType, fieldConf, name, fieldErrors); !ok {
+			return nil
+		}
+	} else if vv, err = castString(vs[0], fieldConf.ElemType, fieldConf.Layout); err != nil {
+		*fieldErrors = append(*fieldErrors, &FormError{Type: FormErrTypeCannotCast, FieldName: name, Err: err})
+		return nil
+	}
+
+	if !fieldV.CanSet() {
+		return nil
+	}
+	if fieldConf.IsPointer {
+		ptr := reflect.New(fieldConf.ElemType)
+		ptr.Elem().Set(vv)
+		vv = ptr
+	}
+	fieldV.Set(vv)
+
+	checkRules(fieldConf, name, fieldV, fieldErrors)
+	return nil
+}
+
+// fillNested fill a nested form, a nil pointer is kept if none of its params presents
+func (r *Request) fillNested(fieldConf *FieldConfig, name string, fieldV reflect.Value, src *formSource, fieldErrors *FormErrors) error {
+	keys, err := src.keysWithPrefix(name + ".")
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		if fieldConf.IsRequired {
+			*fieldErrors = append(*fieldErrors, &FormError{Type: FormErrMissingRequired, FieldName: name})
+			return nil
+		}
+		if fieldConf.IsPointer {
+			return nil
+		}
+	}
+
+	nested := reflect.New(fieldConf.ElemType).Elem()
+	if err := r.fillStruct(nested, fieldConf.Nested, name, src, fieldErrors); err != nil {
+		return err
+	}
+	if fieldConf.IsPointer {
+		nested = nested.Addr()
+	}
+	fieldV.Set(nested)
+	return nil
+}
+
+// fillNestedSlice fill a slice of nested forms addressed as name[0].field, indexes are compacted in order
+func (r *Request) fillNestedSlice(fieldConf *FieldConfig, name string, fieldV reflect.Value, src *formSource, fieldErrors *FormErrors) error {
+	keys, err := src.keysWithPrefix(name + "[")
+	if err != nil {
+		return err
+	}
+
+	indexes := make([]int, 0)
+	seen := make(map[int]bool, 0)
+	for _, key := range keys {
+		rest := key[len(name)+1:]
+		end := strings.IndexByte(rest, ']')
+		if end < 0 || !strings.HasPrefix(rest[end+1:], ".") {
+			continue
+		}
+		i, err := strconv.Atoi(rest[:end])
+		if err != nil || i < 0 || seen[i] {
+			continue
+		}
+		seen[i] = true
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	if len(indexes) == 0 {
+		if fieldConf.IsRequired {
+			*fieldErrors = append(*fieldErrors, &FormError{Type: FormErrMissingRequired, FieldName: name})
+		}
+		return nil
+	}
+
+	sliceType := fieldConf.ElemType
+	isElemPointer := sliceType.Kind() == reflect.Ptr
+	list := reflect.MakeSlice(reflect.SliceOf(sliceType), 0, len(indexes))
+	for _, i := range indexes {
+		elem := reflect.New(*fieldConf.Nested.Type).Elem()
+		if err := r.fillStruct(elem, fieldConf.Nested, name+"["+strconv.Itoa(i)+"]", src, fieldErrors); err != nil {
+			return err
+		}
+		if isElemPointer {
+			elem = elem.Addr()
+		}
+		list = reflect.Append(list, elem)
+	}
+
+	if fieldConf.IsPointer {
+		ptr := reflect.New(list.Type())
+		ptr.Elem().Set(list)
+		fieldV.Set(ptr)
+	} else {
+		fieldV.Set(list)
+	}
+	checkRules(fieldConf, name, fieldV, fieldErrors)
+	return nil
+}
+
+// fillMap fill a map with string keys addressed as name[key] or name.key
+func (r *Request) fillMap(fieldConf *FieldConfig, name string, fieldV reflect.Value, src *formSource, fieldErrors *FormErrors) error {
+	keys, err := src.keysWithPrefix(name + ".")
+	if err != nil {
+		return err
+	}
+
+	mapType := indirectType(*fieldConf.Type)
+	m := reflect.MakeMap(mapType)
+	for _, key := range keys {
+		mapKey := key[len(name)+1:]
+		vs, _ := src.get(key)
+		if mapKey == "" || len(vs) == 0 {
+			continue
+		}
+		var value reflect.Value
+		if isMultiValueType(fieldConf.ElemType) {
+			split := fieldConf.Split
+			if src.fromBody(key) {
+				split = SplitRepeat
+			}
+			var ok bool
+			if value, ok = castSlice(splitValues(vs, split), fieldConf.ElemType, fieldConf, key, fieldErrors); !ok {
+				continue
+			}
+		} else if value, err = castString(vs[0], fieldConf.ElemType, fieldConf.Layout); err != nil {
+			*fieldErrors = append(*fieldErrors, &FormError{Type: FormErrTypeCannotCast, FieldName: key, Err: err})
+			continue
+		}
+		m.SetMapIndex(reflect.ValueOf(mapKey).Convert(mapType.Key()), value)
+	}
+
+	if m.Len() == 0 {
+		if fieldConf.IsRequired {
+			*fieldErrors = append(*fieldErrors, &FormError{Type: FormErrMissingRequired, FieldName: name})
+		}
+		return nil
+	}
+
+	if fieldConf.IsPointer {
+		ptr := reflect.New(m.Type())
+		ptr.Elem().Set(m)
+		m = ptr
+	}
+	fieldV.Set(m)
+	checkRules(fieldConf, name, fieldV, fieldErrors)
+	return nil
+}
+
+// fillFile fill a field with files uploaded by a multipart form
+func (r *Request) fillFile(fieldConf *FieldConfig, name string, fieldV reflect.Value, fieldErrors *FormErrors) error {
+	if err := r.ParseParam(); err != nil {
+		return err
+	}
+
+	var files []*multipart.FileHeader
+	if r.Req.MultipartForm != nil {
+		files = r.Req.MultipartForm.File[name]
+	}
+	if len(files) == 0 {
+		if fieldConf.IsRequired {
+			*fieldErrors = append(*fieldErrors, &FormError{Type: FormErrMissingRequired, FieldName: name})
+		}
+		return nil
+	}
+
+	if fieldConf.ElemType == typeFileHeaders {
+		fieldV.Set(reflect.ValueOf(files))
+	} else {
+		fieldV.Set(reflect.ValueOf(files[0]))
+	}
+	checkRules(fieldConf, name, fieldV, fieldErrors)
+	return nil
+}
@@ -0,0 +1,211 @@
+package goweb
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	regexpUUID = regexp.MustCompile("^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$")
+)
+
+// FieldRule a validation rule of a form field, decoded from the form tag
+// Supported rules:
+//      min=N       minimum of numbers, minimum length of strings, slices and maps
+//      max=N       maximum of numbers, maximum length of strings, slices and maps
+//      len=N       exact length of strings, slices and maps
+//      pattern=RE  strings must match the regular expression, commas in RE must be escaped as \,
+//      oneof=a|b   value must be one of the listed values
+//      email       strings must be an email address
+//      url         strings must be an absolute url
+//      uuid        strings must be an UUID
+// Rules apply to every element of a slice except min, max and len.
+type FieldRule struct {
+	Name  string
+	Value string
+	check func(v reflect.Value) error
+}
+
+// Check validate a field value
+func (rule *FieldRule) Check(v reflect.Value) error {
+	return rule.check(v)
+}
+
+// splitFormTag split form tag by commas, commas escaped as \, are kept
+func splitFormTag(tagValue string) []string {
+	parts := make([]string, 0)
+	var part strings.Builder
+	for i := 0; i < len(tagValue); i++ {
+		c := tagValue[i]
+		if c == '\\' && i+1 < len(tagValue) && tagValue[i+1] == ',' {
+			part.WriteByte(',')
+			i++
+		} else if c == ',' {
+			parts = append(parts, part.String())
+			part.Reset()
+		} else {
+			part.WriteByte(c)
+		}
+	}
+	return append(parts, part.String())
+}
+
+// valueLength length of strings (in runes), slices and maps, ok is false for other kinds
+func valueLength(v reflect.Value) (int, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(v.String()), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len(), true
+	}
+	return 0, false
+}
+
+// valueNumber number value of ints, uints and floats, ok is false for other kinds
+func valueNumber(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// eachElement apply check to v, or to every element of v if it is a slice
+func eachElement(check func(v reflect.Value) error) func(v reflect.Value) error {
+	return func(v reflect.Value) error {
+		if v.Kind() != reflect.Slice {
+			return check(v)
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := check(v.Index(i)); err != nil {
+				return errors.WithMessage(err, "element "+strconv.Itoa(i))
+			}
+		}
+		return nil
+	}
+}
+
+// stringCheck apply check to string values
+func stringCheck(check func(s string) error) func(v reflect.Value) error {
+	return eachElement(func(v reflect.Value) error {
+		if v.Kind() != reflect.String {
+			return errors.New("not a string")
+		}
+		return check(v.String())
+	})
+}
+
+func newRangeRule(name string, arg string) (func(v reflect.Value) error, error) {
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return nil, err
+	}
+	isMin := name == "min"
+	bound := "at most " + arg
+	if isMin {
+		bound = "at least " + arg
+	}
+	outOfRange := func(n float64) bool {
+		return isMin && n < limit || !isMin && n > limit
+	}
+
+	return func(v reflect.Value) error {
+		if n, ok := valueNumber(v); ok {
+			if outOfRange(n) {
+				return errors.New("must be " + bound)
+			}
+			return nil
+		}
+		if l, ok := valueLength(v); ok {
+			if outOfRange(float64(l)) {
+				return errors.New("length must be " + bound)
+			}
+			return nil
+		}
+		return errors.New(name + " is not applicable to " + v.Kind().String())
+	}, nil
+}
+
+// newFieldRule create a rule by name and argument
+func newFieldRule(name string, arg string) (*FieldRule, error) {
+	rule := &FieldRule{Name: name, Value: arg}
+	var err error
+
+	switch name {
+	case "min", "max":
+		rule.check, err = newRangeRule(name, arg)
+	case "len":
+		var n int
+		if n, err = strconv.Atoi(arg); err == nil {
+			rule.check = func(v reflect.Value) error {
+				if l, ok := valueLength(v); !ok || l != n {
+					return errors.New("length must be " + arg)
+				}
+				return nil
+			}
+		}
+	case "pattern":
+		var re *regexp.Regexp
+		if re, err = regexp.Compile(arg); err == nil {
+			rule.check = stringCheck(func(s string) error {
+				if !re.MatchString(s) {
+					return errors.New("must match " + arg)
+				}
+				return nil
+			})
+		}
+	case "oneof":
+		options := strings.Split(arg, "|")
+		rule.check = eachElement(func(v reflect.Value) error {
+			s := fmt.Sprint(v.Interface())
+			for _, option := range options {
+				if s == option {
+					return nil
+				}
+			}
+			return errors.New("must be one of " + strings.Join(options, ", "))
+		})
+	case "email":
+		rule.check = stringCheck(func(s string) error {
+			addr, err := mail.ParseAddress(s)
+			if err != nil || addr.Address != s {
+				return errors.New("must be an email address")
+			}
+			return nil
+		})
+	case "url":
+		rule.check = stringCheck(func(s string) error {
+			u, err := url.ParseRequestURI(s)
+			if err != nil || u.Scheme == "" || u.Host == "" {
+				return errors.New("must be an absolute url")
+			}
+			return nil
+		})
+	case "uuid":
+		rule.check = stringCheck(func(s string) error {
+			if !regexpUUID.MatchString(s) {
+				return errors.New("must be an UUID")
+			}
+			return nil
+		})
+	default:
+		return nil, errors.New("unknown form rule " + name)
+	}
+
+	if err != nil {
+		return nil, errors.WithMessage(err, "form rule "+name)
+	}
+	return rule, nil
+}
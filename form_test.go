@@ -0,0 +1,229 @@
+package goweb
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type signUpForm struct {
+	Name     string   `form:"name,required,min=2,max=8"`
+	Age      int      `form:"age,min=18,max=120"`
+	Code     string   `form:"code,len=4"`
+	Nickname string   `form:"nickname,pattern=^[a-z]{1\\,3}$"`
+	Plan     string   `form:"plan,oneof=free|pro,default=free"`
+	Email    string   `form:"email,email"`
+	Homepage string   `form:"homepage,url"`
+	Token    string   `form:"token,uuid"`
+	Tags     []string `form:"tags,max=2,oneof=a|b|c"`
+}
+
+func testQueryRequest(query string) *Request {
+	r := NewRequest(httptest.NewRequest(HttpGet, "/?"+query, nil))
+	if err := r.ParseParam(); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func TestFormRules(t *testing.T) {
+	form := &signUpForm{}
+	err := testQueryRequest("name=bob&age=20&code=abcd&nickname=bo&email=bob@example.com&homepage=https://example.com&token=123e4567-e89b-12d3-a456-426614174000&tags=a,c").FillForm(form)
+	assert(err == nil, "valid form rejected")
+	assert(form.Plan == "free", "default value not applied")
+
+	err = testQueryRequest("name=b&age=12&code=abc&nickname=bobby&plan=gold&email=Bob%20<bob@example.com>&homepage=/home&token=123&tags=a,b,c").FillForm(form)
+	var fes FormErrors
+	assert(errors.As(err, &fes), "rule errors should be aggregated")
+
+	failed := make(map[string]bool, 0)
+	for _, fe := range fes {
+		assert(fe.Type == FormErrRuleViolation, "rule error type wrong")
+		failed[fe.FieldName] = true
+	}
+	for _, name := range []string{"name", "age", "code", "nickname", "plan", "email", "homepage", "token", "tags"} {
+		assert(failed[name], "rule violation not reported: "+name)
+	}
+	assert(len(fes) == 9, "every failing field should be reported once")
+
+	err = testQueryRequest("age=abc").FillForm(form)
+	assert(errors.As(err, &fes) && len(fes) == 2 && fes[0].Type == FormErrMissingRequired, "missing field should be reported")
+	assert(fes[1].Type == FormErrTypeCannotCast && fes[1].FieldName == "age", "invalid optional value should be reported")
+	assert(AsHTTPError(err).Status == 400, "form errors should be mapped to 400")
+}
+
+func TestFormRuleInvalidTag(t *testing.T) {
+	type badRuleForm struct {
+		Name string `form:"name,min=abc"`
+	}
+	_, err := InspectForm(&badRuleForm{})
+	assert(err != nil, "invalid rule argument should be rejected")
+
+	type unknownRuleForm struct {
+		Name string `form:"name,nosuchrule"`
+	}
+	_, err = InspectForm(&unknownRuleForm{})
+	assert(err != nil, "unknown rule should be rejected")
+}
+
+type testPagination struct {
+	Page int `form:"page,default=1"`
+	Size int `form:"size,default=20,max=100"`
+}
+
+type testAddress struct {
+	City string `form:"city,required"`
+	Zip  string `form:"zip"`
+}
+
+type testOrderItem struct {
+	SKU   string `form:"sku,required"`
+	Count int    `form:"count,min=1"`
+}
+
+type orderForm struct {
+	testPagination
+	Note     *string           `form:"note"`
+	Count    *int              `form:"count"`
+	Address  testAddress       `form:"address"`
+	Billing  *testAddress      `form:"billing"`
+	Items    []*testOrderItem  `form:"items,max=3"`
+	Labels   map[string]string `form:"labels"`
+	Trace    string            `form:"X-Trace,header"`
+	Internal string
+}
+
+func TestFillNestedForm(t *testing.T) {
+	form := &orderForm{}
+	err := testQueryRequest("count=0&address.city=sh&items[2].sku=b&items[0].sku=a&items[0].count=2&labels[color]=red&labels.size=L&size=50").FillForm(form)
+	assert(err == nil, "fill nested form failed")
+	assert(form.Page == 1 && form.Size == 50, "embedded form wrong")
+	assert(form.Note == nil && form.Count != nil && *form.Count == 0, "pointer fields wrong")
+	assert(form.Address.City == "sh" && form.Billing == nil, "nested form wrong")
+	assert(len(form.Items) == 2 && form.Items[0].SKU == "a" && form.Items[0].Count == 2 && form.Items[1].SKU == "b", "slice of nested forms wrong")
+	assert(len(form.Labels) == 2 && form.Labels["color"] == "red" && form.Labels["size"] == "L", "map field wrong")
+
+	err = testQueryRequest("address[city]=sh&billing[zip]=200000&items[0].count=0").FillForm(form)
+	var fes FormErrors
+	assert(errors.As(err, &fes), "nested errors should be aggregated")
+	failed := make(map[string]int, 0)
+	for _, fe := range fes {
+		failed[fe.FieldName] = fe.Type
+	}
+	assert(len(fes) == 3, "every failing nested field should be reported once")
+	assert(failed["billing.city"] == FormErrMissingRequired, "nested required field not reported")
+	assert(failed["items[0].sku"] == FormErrMissingRequired, "required field in slice not reported")
+	assert(failed["items[0].count"] == FormErrRuleViolation, "rule in slice not checked")
+
+	r := testBodyRequest("application/json", `{"title":"t","address":{"city":"sh"},"billing":{"city":"bj"},"items":[{"sku":"k1","count":1}],"labels":{"a":"1"}}`)
+	form = &orderForm{}
+	err = r.BindBody(form)
+	assert(err == nil && form.Address.City == "sh" && form.Billing.City == "bj", "nested JSON wrong")
+	assert(len(form.Items) == 1 && form.Items[0].SKU == "k1" && form.Labels["a"] == "1", "nested JSON slice and map wrong")
+}
+
+func TestNormalizeParamName(t *testing.T) {
+	assert(normalizeParamName("user[address][city]") == "user.address.city", "bracketed name wrong")
+	assert(normalizeParamName("items[0][sku]") == "items[0].sku", "index should be kept")
+	assert(normalizeParamName("tags[]") == "tags", "empty brackets should be dropped")
+}
+
+type multiValueForm struct {
+	Tags    []string `form:"tag"`
+	IDs     []int    `form:"id,max=3"`
+	Filters []string `form:"filter,split=pipe"`
+	Queries []string `form:"q,split=repeat"`
+	Langs   []string `form:"Accept-Language,header,split=repeat"`
+	Codings []string `form:"Accept-Encoding,header"`
+}
+
+func TestFillMultiValueForm(t *testing.T) {
+	r := testQueryRequest("tag=a&tag=b,c&id=1&id=2&filter=x|y&filter=z&q=a,b&q=c")
+	r.Req.Header.Add("Accept-Language", "en")
+	r.Req.Header.Add("Accept-Language", "zh")
+	form := &multiValueForm{}
+	err := r.FillForm(form)
+	assert(err == nil, "fill multi value form failed")
+	assert(strings.Join(form.Tags, " ") == "a b c", "repeated and comma separated values wrong")
+	assert(len(form.IDs) == 2 && form.IDs[1] == 2, "repeated int values wrong")
+	assert(strings.Join(form.Filters, " ") == "x y z", "pipe separated values wrong")
+	assert(strings.Join(form.Queries, " ") == "a,b c", "repeated values should not be split")
+	assert(strings.Join(form.Langs, " ") == "en zh", "repeated headers wrong")
+
+	r = testQueryRequest("id=1,%202&tag=a,%20b")
+	r.Req.Header.Set("Accept-Encoding", "gzip, br")
+	form = &multiValueForm{}
+	err = r.FillForm(form)
+	assert(err == nil && len(form.IDs) == 2 && form.IDs[1] == 2, "spaces after commas should be trimmed")
+	assert(strings.Join(form.Codings, "|") == "gzip|br" && strings.Join(form.Tags, "|") == "a|b", "header list with spaces wrong")
+
+	err = testQueryRequest("id=1&id=x&id=3,y").FillForm(form)
+	var fes FormErrors
+	assert(errors.As(err, &fes) && len(fes) == 2, "every invalid element should be reported")
+	assert(fes[0].FieldName == "id[1]" && fes[1].FieldName == "id[3]" && fes[0].Type == FormErrTypeCannotCast, "index of invalid element wrong")
+
+	type badSplitForm struct {
+		Tags []string `form:"tag,split=semicolon"`
+	}
+	_, err = InspectForm(&badSplitForm{})
+	assert(err != nil, "unknown split should be rejected")
+}
+
+type testTreeNode struct {
+	Name   string        `form:"name"`
+	Parent *testTreeNode `form:"parent"`
+}
+
+func testLocalForm() interface{} {
+	type localForm struct {
+		ID int `form:"id"`
+	}
+	return &localForm{}
+}
+
+func TestFormCache(t *testing.T) {
+	type localForm struct {
+		Name string `form:"name"`
+	}
+	meta, err := InspectForm(&localForm{})
+	assert(err == nil && meta.FieldNames[0] == "Name", "local form wrong")
+	meta, err = InspectForm(testLocalForm())
+	assert(err == nil && meta.FieldNames[0] == "ID", "local forms with the same name should not collide")
+
+	meta, err = InspectForm(&struct {
+		A string `form:"a"`
+	}{})
+	assert(err == nil && meta.FieldNames[0] == "A", "anonymous form wrong")
+	meta, err = InspectForm(&struct {
+		B string `form:"b"`
+	}{})
+	assert(err == nil && meta.FieldNames[0] == "B", "anonymous forms should not collide")
+
+	meta, err = InspectForm(&testTreeNode{})
+	assert(err == nil && meta.FieldMap["Parent"].Nested == meta, "self referencing form wrong")
+	form := &testTreeNode{}
+	err = testQueryRequest("name=a&parent.name=b&parent.parent.name=c").FillForm(form)
+	assert(err == nil && form.Parent.Parent.Name == "c" && form.Parent.Parent.Parent == nil, "self referencing form not filled")
+
+	done := make(chan *FormMeta)
+	for i := 0; i < 8; i++ {
+		go func() {
+			meta, _ := InspectForm(&orderForm{})
+			done <- meta
+		}()
+	}
+	first := <-done
+	for i := 1; i < 8; i++ {
+		assert(<-done == first, "concurrent InspectForm should share cached meta")
+	}
+
+	RegisterForms(&signUpForm{}, &multiValueForm{})
+	defer func() {
+		assert(recover() != nil, "invalid form should panic at registration")
+	}()
+	RegisterForms(&struct {
+		Name string `form:"name,nosuchrule"`
+	}{})
+}
@@ -0,0 +1,232 @@
+package goweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// HTTPError an error carries HTTP status code and a structured body for the client
+type HTTPError struct {
+	Status  int         // HTTP status code of the response
+	Code    string      // machine readable error code, eg: not_found
+	Message string      // human readable message
+	Details interface{} // extra info about the error, eg: the invalid field
+	Err     error       // the wrapped cause, never sent to the client
+}
+
+// httpErrorBody body of an error response
+type httpErrorBody struct {
+	XMLName   xml.Name    `json:"-" xml:"error"`
+	Code      string      `json:"code" xml:"code"`
+	Message   string      `json:"message" xml:"message"`
+	Details   interface{} `json:"details,omitempty" xml:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty" xml:"request_id,omitempty"`
+}
+
+// ErrorDetail a key/value item of error details
+type ErrorDetail struct {
+	Key   string
+	Value string
+}
+
+// ErrorDetails ordered key/value details of an error
+// It is encoded as an object in JSON, and as detail elements with a key attribute in XML, which can not encode maps.
+type ErrorDetails []ErrorDetail
+
+// Get value of key, empty if not found
+func (d ErrorDetails) Get(key string) string {
+	for _, item := range d {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return ""
+}
+
+// MarshalJSON encode details as an object, keys keep their order
+func (d ErrorDetails) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, item := range d {
+		// keys may come from the client, such as keys of map fields, they are escaped as JSON strings
+		key, err := json.Marshal(item.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(item.Value)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// MarshalXML encode details as <detail key="K">V</detail> elements
+func (d ErrorDetails) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, item := range d {
+		detail := xml.StartElement{
+			Name: xml.Name{Local: "detail"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: item.Key}},
+		}
+		if err := e.EncodeElement(item.Value, detail); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// errorCode convert a status code to a default error code, eg: 404 => not_found
+func errorCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.Replace(strings.ToLower(text), " ", "_", -1)
+}
+
+// NewHTTPError create an HTTPError with status code and message
+func NewHTTPError(status int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &HTTPError{
+		Status:  status,
+		Code:    errorCode(status),
+		Message: message,
+	}
+}
+
+// WrapHTTPError wrap an error with status code, the message of err is not exposed to the client
+func WrapHTTPError(err error, status int) *HTTPError {
+	e := NewHTTPError(status, "")
+	e.Err = err
+	return e
+}
+
+// WithCode set error code
+func (e *HTTPError) WithCode(code string) *HTTPError {
+	e.Code = code
+	return e
+}
+
+// WithDetails set error details
+func (e *HTTPError) WithDetails(details interface{}) *HTTPError {
+	e.Details = details
+	return e
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap return the wrapped cause
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// Cause return the wrapped cause, compatible with github.com/pkg/errors
+func (e *HTTPError) Cause() error {
+	return e.Err
+}
+
+// AsHTTPError convert any error to HTTPError
+// FormError and FormErrors are mapped to 400, ErrUnknowContentType and ErrUnsupportedContentType to 415, other errors to 500.
+func AsHTTPError(err error) *HTTPError {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return WrapHTTPError(err, http.StatusRequestEntityTooLarge).WithCode("body_too_large")
+	}
+
+	var formErrs FormErrors
+	if errors.As(err, &formErrs) {
+		fields := make([]ErrorDetails, 0, len(formErrs))
+		for _, fe := range formErrs {
+			fields = append(fields, ErrorDetails{{Key: "field", Value: fe.FieldName}, {Key: "error", Value: fe.Error()}})
+		}
+		return WrapHTTPError(err, http.StatusBadRequest).WithCode("invalid_form").WithDetails(fields)
+	}
+
+	var formErr *FormError
+	if errors.As(err, &formErr) {
+		httpErr := WrapHTTPError(err, http.StatusBadRequest).WithCode("invalid_form")
+		httpErr.Message = formErr.Error()
+		return httpErr.WithDetails(ErrorDetails{{Key: "field", Value: formErr.FieldName}})
+	}
+
+	if errors.Is(err, ErrUnknowContentType) || errors.Is(err, ErrUnsupportedContentType) {
+		return WrapHTTPError(err, http.StatusUnsupportedMediaType)
+	}
+
+	if errors.Is(err, ErrNotAcceptable) {
+		return WrapHTTPError(err, http.StatusNotAcceptable)
+	}
+
+	return WrapHTTPError(err, http.StatusInternalServerError)
+}
+
+// acceptsXML test if the client prefers XML to JSON
+func acceptsXML(accept string) bool {
+	mediaType := negotiateMediaType(accept, []string{MediaTypeJSON, MediaTypeXML, MediaTypeTextXML})
+	return mediaType == MediaTypeXML || mediaType == MediaTypeTextXML
+}
+
+// DefaultErrorHandler render the error as JSON or XML according to the Accept header
+// Nothing is written if the response has been sent.
+func DefaultErrorHandler(err error, resp *Response, ctx *RequestContext) {
+	if ctx.Finished() {
+		return
+	}
+
+	httpErr := AsHTTPError(err)
+	body := &httpErrorBody{
+		Code:      httpErr.Code,
+		Message:   httpErr.Message,
+		Details:   httpErr.Details,
+		RequestID: ctx.RequestID,
+	}
+
+	var data []byte
+	var encodeErr error
+	if acceptsXML(resp.Context.Request.Header("Accept")) {
+		resp.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		if data, encodeErr = xml.Marshal(body); encodeErr != nil {
+			// details may not be encodable as XML
+			body.Details = nil
+			data, encodeErr = xml.Marshal(body)
+		}
+	} else {
+		resp.Header().Set("Content-Type", "application/json; charset=utf-8")
+		data, encodeErr = json.Marshal(body)
+	}
+	if encodeErr != nil {
+		resp.Header().Del("Content-Type")
+		resp.WriteHeader(httpErr.Status)
+		return
+	}
+
+	resp.WriteHeader(httpErr.Status)
+	resp.Write(data)
+}
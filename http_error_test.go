@@ -0,0 +1,66 @@
+package goweb
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func testErrorResponse(err error, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(HttpGet, "/", nil)
+	req.Header.Set("Accept", accept)
+	w := httptest.NewRecorder()
+	ctx := NewRequestContext(NewRequest(req))
+	DefaultErrorHandler(err, &Response{Writer: w, Context: ctx}, ctx)
+	return w
+}
+
+func TestAsHTTPError(t *testing.T) {
+	e := AsHTTPError(newFormError(FormErrMissingRequired, "title", nil))
+	assert(e.Status == 400 && e.Details.(ErrorDetails).Get("field") == "title", "form error mapping wrong")
+
+	e = AsHTTPError(errors.WithMessage(ErrUnknowContentType, "parse"))
+	assert(e.Status == 415, "content type error mapping wrong")
+
+	notFound := NewHTTPError(404, "no such user")
+	e = AsHTTPError(errors.Wrap(notFound, "load user"))
+	assert(e == notFound, "wrapped HTTPError should be found")
+
+	cause := errors.New("db down")
+	e = AsHTTPError(cause)
+	assert(e.Status == 500 && e.Message == "Internal Server Error" && errors.Cause(e) == cause, "unknown error mapping wrong")
+}
+
+func TestDefaultErrorHandler(t *testing.T) {
+	w := testErrorResponse(NewHTTPError(404, "no such user").WithDetails(map[string]string{"id": "1"}), "application/json")
+	body := make(map[string]interface{})
+	json.Unmarshal(w.Body.Bytes(), &body)
+	assert(w.Code == 404 && strings.HasPrefix(w.Header().Get("Content-Type"), "application/json"), "JSON error response wrong")
+	assert(body["code"] == "not_found" && body["message"] == "no such user", "JSON error body wrong")
+
+	w = testErrorResponse(newFormError(FormErrMissingRequired, "title", nil), "application/xml, application/json;q=0.9")
+	assert(w.Code == 400 && strings.HasPrefix(w.Header().Get("Content-Type"), "application/xml"), "XML error response wrong")
+	assert(strings.Contains(w.Body.String(), "<code>invalid_form</code>"), "XML error body wrong")
+	assert(strings.Contains(w.Body.String(), `<details><detail key="field">title</detail></details>`), "XML error details wrong: "+w.Body.String())
+
+	w = testErrorResponse(FormErrors{{Type: FormErrMissingRequired, FieldName: "a"}, {Type: FormErrMissingRequired, FieldName: "b"}}, "application/json")
+	json.Unmarshal(w.Body.Bytes(), &body)
+	fields, _ := body["details"].([]interface{})
+	assert(len(fields) == 2 && fields[1].(map[string]interface{})["field"] == "b", "JSON form error details wrong")
+
+	w = testErrorResponse(FormErrors{{Type: FormErrMissingRequired, FieldName: "a"}, {Type: FormErrMissingRequired, FieldName: "b"}}, "application/xml")
+	assert(strings.Count(w.Body.String(), `<detail key="field">`) == 2, "XML form error details wrong: "+w.Body.String())
+
+	details := ErrorDetails{{Key: "labels.\x1f\"ключ<", Value: "значение\n"}}
+	data, err := json.Marshal(&httpErrorBody{Code: "c", Message: "m", Details: details})
+	assert(err == nil, "details with control characters should be encoded")
+	body = make(map[string]interface{})
+	assert(json.Unmarshal(data, &body) == nil, "encoded details invalid")
+	assert(body["details"].(map[string]interface{})["labels.\x1f\"ключ<"] == "значение\n", "escaped details key wrong")
+
+	w = testErrorResponse(errors.New("secret"), "")
+	assert(w.Code == 500 && !strings.Contains(w.Body.String(), "secret"), "internal error should not be exposed")
+}
@@ -3,6 +3,9 @@ package goweb
 // RequestHandlerFunc Request handle func
 type RequestHandlerFunc func(req *Request, resp *Response, ctx *RequestContext) error
 
+// Middleware wraps a RequestHandlerFunc, it can run code before and after next and observe its result
+type Middleware func(next RequestHandlerFunc) RequestHandlerFunc
+
 // ErrorHandlerFunc Error handle func
 type ErrorHandlerFunc func(err error, resp *Response, ctx *RequestContext)
 
@@ -18,10 +21,17 @@ type FormValidator interface {
 type RequestFilterFunc func(resp *Response, ctx *RequestContext) error
 
 // RequestFilter a filter to pre-process request before it goes to RequestHandler
+// See Filter for filters with access to the Request and the error of the handler.
 type RequestFilter interface {
 	FilterRequest(resp *Response, ctx *RequestContext) error
 }
 
+// BeforeFilterFunc before phase of a Filter
+type BeforeFilterFunc func(req *Request, resp *Response, ctx *RequestContext) error
+
+// AfterFilterFunc after phase of a Filter, err is the error returned by the handler, the returned error is passed on
+type AfterFilterFunc func(req *Request, resp *Response, ctx *RequestContext, err error) error
+
 // RequestHandler handle request and generate response
 type RequestHandler interface {
 	HandleRequest(req *Request, resp *Response, ctx *RequestContext) error
@@ -0,0 +1,11 @@
+package goweb
+
+// applyMiddlewares wrap h with middlewares, the first middleware is the outermost one
+// Middlewares are applied in the order AppServer > RouterHub > Router.
+// Each level composes its chain once on its first request, middlewares must be added before serving.
+func applyMiddlewares(h RequestHandlerFunc, middlewares []Middleware) RequestHandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
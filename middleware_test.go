@@ -0,0 +1,108 @@
+package goweb
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func traceMiddleware(trace *[]string, name string) Middleware {
+	return func(next RequestHandlerFunc) RequestHandlerFunc {
+		return func(req *Request, resp *Response, ctx *RequestContext) error {
+			*trace = append(*trace, ">"+name)
+			err := next(req, resp, ctx)
+			if err != nil {
+				*trace = append(*trace, "!"+name)
+			}
+			*trace = append(*trace, "<"+name)
+			return err
+		}
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	trace := make([]string, 0)
+	var handledErr error
+	server := NewAppServer(&AppServerConfig{
+		ErrorHandlerFunc: func(err error, resp *Response, ctx *RequestContext) {
+			handledErr = err
+		},
+	})
+	server.Use(traceMiddleware(&trace, "s1"), traceMiddleware(&trace, "s2"))
+
+	errFailed := errors.New("failed")
+	hub := NewRouterHub("/mw/")
+	hub.Use(traceMiddleware(&trace, "h"))
+	router := NewRouter("/mw/:id", func(req *Request, resp *Response, ctx *RequestContext) error {
+		trace = append(trace, "handler")
+		return errFailed
+	}, nil)
+	router.Use(traceMiddleware(&trace, "r"))
+	hub.AddRouter(router)
+	server.AddHub(hub)
+
+	server.ServeMux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(HttpGet, "/mw/1", nil))
+
+	expected := []string{">s1", ">s2", ">h", ">r", "handler", "!r", "<r", "!h", "<h", "!s2", "<s2", "!s1", "<s1"}
+	assert(len(trace) == len(expected), "middleware trace wrong")
+	for i := range expected {
+		assert(trace[i] == expected[i], "middleware order wrong")
+	}
+	assert(handledErr == errFailed, "middleware should pass the error")
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	server := NewAppServer(&AppServerConfig{})
+	server.Use(func(next RequestHandlerFunc) RequestHandlerFunc {
+		return func(req *Request, resp *Response, ctx *RequestContext) error {
+			if req.Header("Authorization") == "" {
+				resp.WriteHeader(401)
+				return nil
+			}
+			return next(req, resp, ctx)
+		}
+	})
+	server.GET("/private", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return resp.WriteString("secret")
+	})
+	server.prepare()
+
+	w := httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, httptest.NewRequest(HttpGet, "/private", nil))
+	assert(w.Code == 401, "middleware should short circuit")
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(HttpGet, "/private", nil)
+	req.Header.Set("Authorization", "token")
+	server.ServeMux.ServeHTTP(w, req)
+	assert(w.Code == 200 && w.Body.String() == "secret", "middleware should call next")
+}
+
+func TestMiddlewareComposedOnce(t *testing.T) {
+	composed := 0
+	counting := func(next RequestHandlerFunc) RequestHandlerFunc {
+		composed++
+		return next
+	}
+	trace := make([]string, 0)
+	server := NewAppServer(&AppServerConfig{})
+	server.Use(counting)
+	server.GET("/once", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return resp.WriteString("ok")
+	}).Use(counting, traceMiddleware(&trace, "r"))
+	hub := NewRouterHub("/hub/")
+	hub.Use(counting)
+	hub.GET("/hub/once", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return resp.WriteString("ok")
+	})
+	server.AddHub(hub)
+	server.prepare()
+
+	for i := 0; i < 3; i++ {
+		server.ServeMux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(HttpGet, "/once", nil))
+		server.ServeMux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(HttpGet, "/hub/once", nil))
+	}
+	// two server adapters, the router of /once and the hub
+	assert(composed == 4, "middleware chains should be composed once per level")
+	assert(len(trace) == 6, "middleware of the router returned by GET should run")
+}
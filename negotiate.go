@@ -0,0 +1,237 @@
+package goweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// media types of the built-in encoders
+const (
+	MediaTypeJSON    = "application/json"
+	MediaTypeXML     = "application/xml"
+	MediaTypeTextXML = "text/xml"
+	MediaTypeHTML    = "text/html"
+	MediaTypeText    = "text/plain"
+)
+
+// Encoder encode data to w, it is used by Response.Negotiate for the media type it is registered for
+type Encoder func(w io.Writer, data interface{}) error
+
+var (
+	encoders      = make(map[string]Encoder, 0)
+	encoderTypes  = make([]string, 0) // registration order of custom media types
+	encodersMutex sync.RWMutex
+)
+
+// RegisterEncoder register an encoder for a media type, it replaces the built-in encoder of the same media type
+// Custom media types are offered after the built-in ones, in the order they are registered.
+func RegisterEncoder(mediaType string, encoder Encoder) {
+	mediaType = strings.ToLower(mediaType)
+	encodersMutex.Lock()
+	defer encodersMutex.Unlock()
+	if _, exists := encoders[mediaType]; !exists {
+		encoderTypes = append(encoderTypes, mediaType)
+	}
+	encoders[mediaType] = encoder
+}
+
+func lookupEncoder(mediaType string) Encoder {
+	encodersMutex.RLock()
+	defer encodersMutex.RUnlock()
+	return encoders[mediaType]
+}
+
+// TemplateView a template with the data to render
+// Response.Negotiate renders it as HTML, other media types encode Data only.
+type TemplateView struct {
+	Template *template.Template
+	Name     string // name of the template to execute, the template itself if empty
+	Data     interface{}
+}
+
+func (view *TemplateView) render(w io.Writer) error {
+	if view.Name == "" {
+		return view.Template.Execute(w, view.Data)
+	}
+	return view.Template.ExecuteTemplate(w, view.Name, view.Data)
+}
+
+// mediaRange a media range in the Accept header, eg: text/*;q=0.8
+type mediaRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// specificity how specific the range matches mediaType, 0 if it does not match
+func (r *mediaRange) specificity(mediaType string) int {
+	typ, subtype := splitMediaType(mediaType)
+	switch {
+	case r.typ == "*" && r.subtype == "*":
+		return 1
+	case r.typ == typ && r.subtype == "*":
+		return 2
+	case r.typ == typ && r.subtype == subtype:
+		return 3
+	}
+	return 0
+}
+
+func splitMediaType(mediaType string) (string, string) {
+	if i := strings.IndexByte(mediaType, '/'); i >= 0 {
+		return mediaType[:i], mediaType[i+1:]
+	}
+	return mediaType, ""
+}
+
+// parseAccept parse media ranges of an Accept header, invalid ranges are skipped
+func parseAccept(accept string) []*mediaRange {
+	ranges := make([]*mediaRange, 0)
+	for _, item := range strings.Split(accept, ",") {
+		params := strings.Split(item, ";")
+		typ, subtype := splitMediaType(strings.ToLower(strings.TrimSpace(params[0])))
+		if typ == "" || subtype == "" {
+			continue
+		}
+
+		r := &mediaRange{typ: typ, subtype: subtype, q: 1}
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q >= 0 && q <= 1 {
+					r.q = q
+				}
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// negotiateMediaType select the offer the client prefers most according to the Accept header
+// The quality of an offer is given by the most specific range matching it, ties are broken by the order of offers.
+// The first offer is selected if the header is empty, an empty string is returned if nothing is acceptable.
+func negotiateMediaType(accept string, offers []string) string {
+	if strings.TrimSpace(accept) == "" {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, specificity := 0.0, 0
+		for _, r := range ranges {
+			if s := r.specificity(offer); s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// negotiationOffers media types Negotiate can produce for data
+func negotiationOffers(data interface{}) []string {
+	offers := make([]string, 0, 5)
+	if _, ok := data.(*TemplateView); ok {
+		offers = append(offers, MediaTypeHTML)
+	}
+	offers = append(offers, MediaTypeJSON, MediaTypeXML, MediaTypeTextXML, MediaTypeText)
+
+	encodersMutex.RLock()
+	defer encodersMutex.RUnlock()
+	for _, mediaType := range encoderTypes {
+		builtin := false
+		for _, offer := range offers {
+			builtin = builtin || offer == mediaType
+		}
+		if !builtin {
+			offers = append(offers, mediaType)
+		}
+	}
+	return offers
+}
+
+// encodeMediaType encode data as mediaType
+func encodeMediaType(w io.Writer, mediaType string, data interface{}) error {
+	if view, ok := data.(*TemplateView); ok && mediaType != MediaTypeHTML {
+		data = view.Data
+	}
+	if encoder := lookupEncoder(mediaType); encoder != nil {
+		return encoder(w, data)
+	}
+
+	switch mediaType {
+	case MediaTypeHTML:
+		if view, ok := data.(*TemplateView); ok {
+			return view.render(w)
+		}
+	case MediaTypeJSON:
+		return json.NewEncoder(w).Encode(data)
+	case MediaTypeXML, MediaTypeTextXML:
+		return xml.NewEncoder(w).Encode(data)
+	case MediaTypeText:
+		_, err := fmt.Fprint(w, data)
+		return err
+	}
+	return ErrNotAcceptable
+}
+
+// addVary add token to the Vary header, unless it is listed already
+func addVary(header http.Header, token string) {
+	for _, value := range header.Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			existing = strings.TrimSpace(existing)
+			if existing == "*" || strings.EqualFold(existing, token) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", token)
+}
+
+// Negotiate write data with status in the media type the client prefers according to the Accept header
+// JSON, XML, plain text and media types with a registered Encoder are offered, HTML is offered if data is a *TemplateView.
+// ErrNotAcceptable is returned if none of them is acceptable, the error handler responds it with 406.
+func (resp *Response) Negotiate(status int, data interface{}) error {
+	if view, ok := data.(TemplateView); ok {
+		data = &view
+	}
+
+	addVary(resp.Header(), "Accept")
+	mediaType := negotiateMediaType(resp.Context.Request.Header("Accept"), negotiationOffers(data))
+	if mediaType == "" {
+		return ErrNotAcceptable
+	}
+
+	// encode before writing header, so encoding errors can still be responded
+	buf := &bytes.Buffer{}
+	if err := encodeMediaType(buf, mediaType, data); err != nil {
+		return err
+	}
+
+	contentType := mediaType
+	if strings.HasPrefix(mediaType, "text/") || mediaType == MediaTypeJSON || mediaType == MediaTypeXML {
+		contentType += "; charset=utf-8"
+	}
+	resp.Header().Set("Content-Type", contentType)
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp.WriteHeader(status)
+	_, err := resp.Write(buf.Bytes())
+	return err
+}
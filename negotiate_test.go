@@ -0,0 +1,94 @@
+package goweb
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type negotiateItem struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func (item negotiateItem) String() string {
+	return "item " + item.Name
+}
+
+func testNegotiate(accept string, data interface{}) (*httptest.ResponseRecorder, error) {
+	req := httptest.NewRequest(HttpGet, "/", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	resp := &Response{Writer: w, Context: NewRequestContext(NewRequest(req))}
+	err := resp.Negotiate(201, data)
+	return w, err
+}
+
+func TestNegotiateMediaType(t *testing.T) {
+	offers := []string{MediaTypeJSON, MediaTypeXML, MediaTypeText}
+	cases := map[string]string{
+		"":                               MediaTypeJSON,
+		"*/*":                            MediaTypeJSON,
+		"application/xml":                MediaTypeXML,
+		"application/json;q=0.5, text/*": MediaTypeText,
+		"text/*;q=0.9, text/plain;q=0.1, application/json;q=0.2": MediaTypeJSON,
+		"*/*;q=0.1, application/xml":                             MediaTypeXML,
+		"image/png":                                              "",
+		"application/json;q=0, */*":                              MediaTypeXML,
+	}
+	for accept, expected := range cases {
+		assert(negotiateMediaType(accept, offers) == expected, "negotiation wrong: "+accept)
+	}
+}
+
+func TestResponseNegotiate(t *testing.T) {
+	item := negotiateItem{Name: "book"}
+
+	w, err := testNegotiate("application/json", item)
+	assert(err == nil && w.Code == 201 && strings.TrimSpace(w.Body.String()) == `{"name":"book"}`, "JSON wrong")
+	assert(w.Header().Get("Content-Type") == "application/json; charset=utf-8" && w.Header().Get("Vary") == "Accept", "JSON headers wrong")
+
+	w, err = testNegotiate("text/xml", item)
+	assert(err == nil && w.Body.String() == "<negotiateItem><name>book</name></negotiateItem>", "XML wrong")
+	assert(w.Header().Get("Content-Type") == "text/xml; charset=utf-8", "XML content type wrong")
+
+	w, err = testNegotiate("text/plain", item)
+	assert(err == nil && w.Body.String() == "item book", "text wrong")
+
+	_, err = testNegotiate("text/html", item)
+	assert(err == ErrNotAcceptable && AsHTTPError(err).Status == 406, "HTML should not be offered without a template")
+
+	view := &TemplateView{Template: template.Must(template.New("item").Parse("<b>{{.Name}}</b>")), Data: item}
+	w, err = testNegotiate("text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", view)
+	assert(err == nil && w.Body.String() == "<b>book</b>" && w.Header().Get("Content-Type") == "text/html; charset=utf-8", "HTML wrong")
+	w, err = testNegotiate("application/json", view)
+	assert(err == nil && strings.TrimSpace(w.Body.String()) == `{"name":"book"}`, "view data should be encoded for JSON")
+
+	RegisterEncoder("text/csv", func(w io.Writer, data interface{}) error {
+		_, err := io.WriteString(w, "name\n"+data.(negotiateItem).Name+"\n")
+		return err
+	})
+	w, err = testNegotiate("text/csv", item)
+	assert(err == nil && w.Body.String() == "name\nbook\n" && w.Header().Get("Content-Type") == "text/csv; charset=utf-8", "custom encoder wrong")
+}
+
+func TestAddVary(t *testing.T) {
+	header := http.Header{}
+	addVary(header, "Accept")
+	addVary(header, "accept")
+	addVary(header, "Accept-Encoding")
+	addVary(header, "Accept-Encoding")
+	assert(strings.Join(header.Values("Vary"), ", ") == "Accept, Accept-Encoding", "Vary tokens should not be duplicated")
+
+	header = http.Header{"Vary": {"Origin, Accept"}}
+	addVary(header, "Accept")
+	assert(len(header.Values("Vary")) == 1, "token in a list should be found")
+
+	header = http.Header{"Vary": {"*"}}
+	addVary(header, "Accept")
+	assert(len(header.Values("Vary")) == 1, "nothing should be added to Vary: *")
+}
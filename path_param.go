@@ -3,26 +3,59 @@ package goweb
 import (
 	"regexp"
 	"strings"
+
+	"github.com/pkg/errors"
 )
 
 var (
-	regexpPath *regexp.Regexp
+	// builtin path param constraints, other constraints are treated as regular expressions
+	pathParamConstraints = map[string]string{
+		"int":   "-?[0-9]+",
+		"uint":  "[0-9]+",
+		"alpha": "[a-zA-Z]+",
+		"alnum": "[a-zA-Z0-9]+",
+		"uuid":  "[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}",
+	}
 )
 
-func init() {
-	var err error
-	regexpPath, err = regexp.Compile("/:([^/]+)")
-	if err != nil {
-		panic(err)
+// PathParam a param segment of a url pattern
+// Supported forms:
+//      /:name          a path segment
+//      /:name<int>     a path segment satisfying a builtin constraint: int, uint, alpha, alnum, uuid
+//      /:name<[a-z]+>  a path segment matching a regular expression
+//      /:name?         an optional trailing path segment, can be combined with a constraint: /:name<int>?
+//      /*name          the rest of the url path, including slashes
+type PathParam struct {
+	Name       string
+	Constraint string
+	Optional   bool
+	CatchAll   bool
+	matcher    *regexp.Regexp
+}
+
+// MatchValue test if a captured value satisfies the constraint of the param
+func (p *PathParam) MatchValue(v string) bool {
+	if p.matcher == nil {
+		return true
 	}
+	return p.matcher.MatchString(v)
+}
+
+// pathSegment a piece of url pattern, either static text or a param
+type pathSegment struct {
+	static string
+	param  *PathParam
 }
 
 type PathConfig struct {
+	Path       string
 	BasePath   string
 	Domain     string
 	Regexp     *regexp.Regexp
 	ParamNames []string
+	Params     []*PathParam
 	PathDepth  int
+	segments   []pathSegment
 }
 
 // PathDepth get depth of a url path
@@ -30,42 +63,194 @@ func PathDepth(path string) int {
 	return strings.Count(path, "/") - 1
 }
 
-// ParsePathParam Get path config info of a url pattern
-func ParsePathParam(pattern string) *PathConfig {
+// parsePathParam parse a param starts at path[0], which is ':' or '*'
+// Returns the param and the length of pattern it takes
+func parsePathParam(path string) (*PathParam, int, error) {
+	p := &PathParam{CatchAll: path[0] == '*'}
+	i := 1
+	for i < len(path) && path[i] != '/' && path[i] != '<' && path[i] != '?' {
+		i++
+	}
+	p.Name = path[1:i]
+	if p.Name == "" {
+		return nil, 0, errors.New("empty path param name")
+	}
+	if p.CatchAll {
+		if i < len(path) {
+			return nil, 0, errors.New("catch-all param *" + p.Name + " must be the last segment")
+		}
+		return p, i, nil
+	}
+
+	if i < len(path) && path[i] == '<' {
+		depth := 0
+		start := i + 1
+		for ; i < len(path); i++ {
+			if path[i] == '<' {
+				depth++
+			} else if path[i] == '>' {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+		if i == len(path) {
+			return nil, 0, errors.New("unclosed constraint of path param :" + p.Name)
+		}
+		p.Constraint = path[start:i]
+		i++
+
+		expr, found := pathParamConstraints[p.Constraint]
+		if !found {
+			expr = p.Constraint
+		}
+		matcher, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, 0, errors.WithMessage(err, "constraint of path param :"+p.Name)
+		}
+		p.matcher = matcher
+	}
+
+	if i < len(path) && path[i] == '?' {
+		p.Optional = true
+		i++
+	}
+	if i < len(path) && path[i] != '/' {
+		return nil, 0, errors.New("unexpected character after path param :" + p.Name)
+	}
+	return p, i, nil
+}
+
+// parsePathSegments split a url path pattern into static text and params
+// A param only starts right after a slash, optional params can only be followed by other optional params.
+func parsePathSegments(path string) ([]pathSegment, error) {
+	segments := make([]pathSegment, 0)
+	static := 0
+	optional := false
+	for i := 0; i < len(path); {
+		if i == 0 || path[i-1] != '/' || (path[i] != ':' && path[i] != '*') {
+			i++
+			continue
+		}
+		if optional && path[static:i] != "/" {
+			return nil, errors.New("optional path param must be trailing")
+		}
+		if static < i {
+			segments = append(segments, pathSegment{static: path[static:i]})
+		}
+		p, n, err := parsePathParam(path[i:])
+		if err != nil {
+			return nil, err
+		}
+		if optional && !p.Optional {
+			return nil, errors.New("path param :" + p.Name + " follows an optional param")
+		}
+		optional = p.Optional
+		segments = append(segments, pathSegment{param: p})
+		i += n
+		static = i
+	}
+	if static < len(path) {
+		if optional {
+			return nil, errors.New("optional path param must be trailing")
+		}
+		segments = append(segments, pathSegment{static: path[static:]})
+	}
+	return segments, nil
+}
+
+// buildPathRegexp build a regular expression equivalent to the segments
+func buildPathRegexp(segments []pathSegment) (*regexp.Regexp, error) {
+	expr := "^"
+	groups := 0
+	for i, seg := range segments {
+		if seg.param == nil {
+			s := seg.static
+			if i+1 < len(segments) && segments[i+1].param != nil && segments[i+1].param.Optional {
+				s = strings.TrimSuffix(s, "/")
+			}
+			expr += regexp.QuoteMeta(s)
+			continue
+		}
+		if seg.param.Optional {
+			expr += "(?:/"
+			groups++
+		}
+		if seg.param.CatchAll {
+			expr += "(.*)"
+		} else {
+			expr += "([^/]+)"
+		}
+	}
+	expr += strings.Repeat(")?", groups) + "$"
+	return regexp.Compile(expr)
+}
+
+// ParsePathPattern Get path config info of a url pattern
+func ParsePathPattern(pattern string) (*PathConfig, error) {
 	i := strings.Index(pattern, "/")
 	domain := ""
 	path := pattern
-	if i != 0 {
+	if i > 0 {
 		domain = pattern[0:i]
 		path = pattern[i:]
 	}
-	matches := regexpPath.FindAllStringSubmatch(path, -1)
+
+	segments, err := parsePathSegments(path)
+	if err != nil {
+		return nil, errors.WithMessage(errors.Wrap(ErrInvalidPattern, err.Error()), pattern)
+	}
 	pd := PathDepth(path)
-	if matches == nil {
+
+	params := make([]*PathParam, 0)
+	paramNames := make([]string, 0)
+	basePath := ""
+	for _, seg := range segments {
+		if seg.param == nil {
+			if len(params) == 0 {
+				basePath += seg.static
+			}
+			continue
+		}
+		params = append(params, seg.param)
+		paramNames = append(paramNames, seg.param.Name)
+	}
+
+	if len(params) == 0 {
 		return &PathConfig{
+			Path:      path,
 			BasePath:  path,
 			Domain:    domain,
 			PathDepth: pd,
-		}
+			segments:  segments,
+		}, nil
 	}
 
-	paramNames := make([]string, 0)
-	for _, item := range matches {
-		paramNames = append(paramNames, item[1])
-	}
-	regexp, err := regexp.Compile("^" + regexpPath.ReplaceAllLiteralString(path, "/([^/]+)") + "$")
+	regexp, err := buildPathRegexp(segments)
 	if err != nil {
-		panic(err)
+		return nil, errors.WithMessage(errors.Wrap(ErrInvalidPattern, err.Error()), pattern)
 	}
 
-	subMatchIndex := regexpPath.FindStringSubmatchIndex(path)
 	return &PathConfig{
-		BasePath:   path[0 : subMatchIndex[0]+1],
+		Path:       path,
+		BasePath:   basePath,
 		Domain:     domain,
 		Regexp:     regexp,
 		ParamNames: paramNames,
+		Params:     params,
 		PathDepth:  pd,
+		segments:   segments,
+	}, nil
+}
+
+// ParsePathParam Get path config info of a url pattern, it panics if the pattern is invalid
+func ParsePathParam(pattern string) *PathConfig {
+	p, err := ParsePathPattern(pattern)
+	if err != nil {
+		panic(err)
 	}
+	return p
 }
 
 func (p *PathConfig) PatternString() string {
@@ -77,6 +262,30 @@ func (p *PathConfig) IsPlainPath() bool {
 	return p.Regexp == nil
 }
 
+// variants expand optional params, each variant is a list of segments without optional params
+func (p *PathConfig) variants() [][]pathSegment {
+	variants := make([][]pathSegment, 0, 1)
+	for i, seg := range p.segments {
+		if seg.param == nil || !seg.param.Optional {
+			continue
+		}
+		variant := append([]pathSegment{}, p.segments[:i]...)
+		if last := len(variant) - 1; last >= 0 && variant[last].param == nil {
+			static := strings.TrimSuffix(variant[last].static, "/")
+			if static == "" && last == 0 {
+				static = "/"
+			}
+			if static == "" {
+				variant = variant[:last]
+			} else {
+				variant[last] = pathSegment{static: static}
+			}
+		}
+		variants = append(variants, variant)
+	}
+	return append(variants, p.segments)
+}
+
 // Match test if a url path matches the path pattern
 // Return test result as bool in first return value
 // The second return value contains captured path params as type map[string]string
@@ -84,13 +293,21 @@ func (p *PathConfig) Match(path string) (bool, map[string]string) {
 	if p.Regexp == nil {
 		return p.BasePath == path, nil
 	}
-	if !p.Regexp.MatchString(path) {
+	matches := p.Regexp.FindStringSubmatchIndex(path)
+	if matches == nil {
 		return false, nil
 	}
-	matches := p.Regexp.FindStringSubmatch(path)
 	params := make(map[string]string, 0)
-	for i, item := range matches[1:] {
-		params[p.ParamNames[i]] = item
+	for i, param := range p.Params {
+		start, end := matches[2*i+2], matches[2*i+3]
+		if start < 0 {
+			continue
+		}
+		v := path[start:end]
+		if !param.MatchValue(v) {
+			return false, nil
+		}
+		params[param.Name] = v
 	}
 	return true, params
 }
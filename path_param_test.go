@@ -3,6 +3,8 @@ package goweb
 import (
 	"fmt"
 	"testing"
+
+	"github.com/pkg/errors"
 )
 
 func assert(expr bool, failureWords string) {
@@ -53,3 +55,31 @@ func TestParsePathParam(t *testing.T) {
 	fmt.Println(params)
 	assert(match && params["siteId"] == "1234" && params["pluginId"] == "1234", "match wrong")
 }
+
+func TestParsePathPattern(t *testing.T) {
+	p := ParsePathParam("/static/*filepath")
+	assert(p.BasePath == "/static/" && p.Params[0].CatchAll, "catch-all parse wrong")
+	match, params := p.Match("/static/js/app.js")
+	assert(match && params["filepath"] == "js/app.js", "catch-all match wrong")
+
+	p = ParsePathParam("/users/:id<int>")
+	assert(p.Params[0].Constraint == "int", "constraint parse wrong")
+	match, params = p.Match("/users/12")
+	assert(match && params["id"] == "12", "constraint match wrong")
+	match, _ = p.Match("/users/bob")
+	assert(!match, "constraint should not match")
+
+	p = ParsePathParam("/archive/:year<[0-9]{4}>/:month?")
+	assert(p.Params[1].Optional, "optional parse wrong")
+	match, params = p.Match("/archive/2019")
+	assert(match && params["year"] == "2019", "optional absent match wrong")
+	match, params = p.Match("/archive/2019/02")
+	assert(match && params["month"] == "02", "optional present match wrong")
+	match, _ = p.Match("/archive/19/02")
+	assert(!match, "regexp constraint should not match")
+
+	for _, pattern := range []string{"/users/:", "/static/*path/x", "/users/:id?/x", "/users/:id?/:name", "/users/:id<int", "/users/:id<(>", "/users/:id<int>x"} {
+		_, err := ParsePathPattern(pattern)
+		assert(errors.Cause(err) == ErrInvalidPattern, "invalid pattern not reported: "+pattern)
+	}
+}
@@ -2,21 +2,33 @@ package goweb
 
 import (
 	"context"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"reflect"
-	"strings"
 )
 
+// DefaultMaxMemory bytes of a multipart form kept in memory by default
+const DefaultMaxMemory = 5 << 20
+
 var (
 	methodsWithBody = map[string]bool{"POST": true, "PUT": true, "PATCH": true}
 )
 
 // Request wraps net/http.Request to provide form parsing ability
 type Request struct {
-	Req       *http.Request
-	URL       *url.URL
-	pathParam map[string]string
+	Req         *http.Request
+	URL         *url.URL
+	pathParam   map[string]string
+	bodyValues  url.Values // values decoded from a JSON or XML body
+	bodyErr     error
+	bodyParsed  bool
+	config      *RouterConfig // config of the matched router
+	match       *routeMatch   // the router looked up by a hub
+	body        io.ReadCloser // the body before limits of the config are applied
+	paramErr    error
+	paramParsed bool
 }
 
 // NewRequest create a request obect from net/http.Request
@@ -38,8 +50,18 @@ func NewRequest(req *http.Request) *Request {
 	}
 }
 
-// ParseParam parse http form
+// ParseParam parse http form, the form is parsed only once
+// Multipart forms are parsed with the MaxMemory of the matched router, or left unparsed if the router streams multipart.
 func (r *Request) ParseParam() error {
+	if r.paramParsed {
+		return r.paramErr
+	}
+	r.paramParsed = true
+	r.paramErr = r.parseParam()
+	return r.paramErr
+}
+
+func (r *Request) parseParam() error {
 	hasBody, _ := methodsWithBody[r.Req.Method]
 	if !hasBody {
 		return nil
@@ -48,18 +70,127 @@ func (r *Request) ParseParam() error {
 	if contentType == "" {
 		return ErrUnknowContentType
 	}
-	parts := strings.Split(contentType, ";")
-	parts[0] = strings.Trim(parts[0], " ")
+	mediaType := parseMediaType(contentType)
 
-	if parts[0] == "application/x-www-form-urlencoded" {
+	if mediaType == "application/x-www-form-urlencoded" {
 		return r.Req.ParseForm()
-	} else if parts[0] == "multipart/form-data" {
-		return r.Req.ParseMultipartForm(5 * (1 << 20))
+	} else if mediaType == "multipart/form-data" {
+		if r.config != nil && r.config.StreamMultipart {
+			// ParseForm ignores multipart bodies, only QueryString is parsed
+			return r.Req.ParseForm()
+		}
+		maxMemory := int64(DefaultMaxMemory)
+		if r.config != nil && r.config.MaxMemory > 0 {
+			maxMemory = r.config.MaxMemory
+		}
+		return r.Req.ParseMultipartForm(maxMemory)
 	}
 
+	// JSON and XML bodies are decoded on demand, only QueryString is parsed here
 	return r.Req.ParseForm()
 }
 
+// applyConfig apply limits of a router config to the body
+// RouterAdapter applies the config of the router matching the path before middlewares and filters run,
+// the matched router applies its own again, unless the body has been read meanwhile.
+func (r *Request) applyConfig(w http.ResponseWriter, config *RouterConfig) error {
+	if config == nil || r.paramParsed || r.bodyParsed {
+		return nil
+	}
+	r.config = config
+
+	if r.body == nil {
+		r.body = r.Req.Body
+	}
+	r.Req.Body = r.body
+	if config.MaxBodySize > 0 && r.body != nil {
+		if r.Req.ContentLength > config.MaxBodySize {
+			return &http.MaxBytesError{Limit: config.MaxBodySize}
+		}
+		r.Req.Body = http.MaxBytesReader(w, r.body, config.MaxBodySize)
+	}
+	return nil
+}
+
+// prepare apply the config of the matched router and parse params
+func (r *Request) prepare(w http.ResponseWriter, config *RouterConfig) error {
+	if err := r.applyConfig(w, config); err != nil {
+		return err
+	}
+	return r.ParseParam()
+}
+
+// MultipartReader get a reader to stream parts of a multipart body
+// Parts are read one by one without being buffered in memory or temporary files.
+// The router must be configured with StreamMultipart, otherwise the body has been consumed by ParseParam.
+func (r *Request) MultipartReader() (*multipart.Reader, error) {
+	return r.Req.MultipartReader()
+}
+
+// EachPart stream parts of a multipart body, fn is called for each part and should read the part before returning
+// Iteration stops at the first error returned by fn.
+func (r *Request) EachPart(fn func(part *multipart.Part) error) error {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = fn(part)
+		part.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// HasStructuredBody test if the request has a JSON or XML body
+func (r *Request) HasStructuredBody() bool {
+	if hasBody, _ := methodsWithBody[r.Req.Method]; !hasBody {
+		return false
+	}
+	mediaType := parseMediaType(r.Header("Content-Type"))
+	return isJSONMediaType(mediaType) || isXMLMediaType(mediaType)
+}
+
+// BodyValues decode a JSON or XML body into flattened values, the body is decoded only once
+// Nested values are addressed with dotted names (address.city) and indexes (items[0].sku).
+func (r *Request) BodyValues() (url.Values, error) {
+	if r.bodyParsed {
+		return r.bodyValues, r.bodyErr
+	}
+	r.bodyParsed = true
+
+	if !r.HasStructuredBody() {
+		r.bodyErr = ErrUnsupportedContentType
+		return nil, r.bodyErr
+	}
+
+	var values url.Values
+	var err error
+	if isJSONMediaType(parseMediaType(r.Header("Content-Type"))) {
+		values, err = decodeJSONValues(r.Req.Body)
+	} else {
+		values, err = decodeXMLValues(r.Req.Body)
+	}
+	if err == io.EOF {
+		// empty body
+		values, err = make(url.Values, 0), nil
+	}
+	if err != nil {
+		r.bodyErr = newFormError(FormErrInvalidBody, "", err)
+		return nil, r.bodyErr
+	}
+	r.bodyValues = values
+	return values, nil
+}
+
 // PathParam get Path Param
 func (r *Request) PathParam(name string) string {
 	return r.pathParam[name]
@@ -80,47 +211,48 @@ func (r *Request) Cookie(name string) string {
 }
 
 // Param get a param from QueryString or Body
+// Values in a JSON or XML body take precedence over QueryString.
+// The body is parsed by ParseParam with limits of the router config, only QueryString is used if it can not be parsed.
 func (r *Request) Param(name string) string {
-	return r.Req.FormValue(name)
+	if r.HasStructuredBody() {
+		if values, err := r.BodyValues(); err == nil {
+			if vs, found := values[name]; found && len(vs) > 0 {
+				return vs[0]
+			}
+		}
+	}
+	if err := r.ParseParam(); err != nil || r.Req.Form == nil {
+		return r.URL.Query().Get(name)
+	}
+	return r.Req.Form.Get(name)
 }
 
 // FillForm populate a form for data validating
+// Params are fetched from JSON or XML body, QueryString or form body, headers, cookies and path according to the form tag.
 func (r *Request) FillForm(m interface{}) error {
+	return r.fillForm(m, false)
+}
+
+// BindBody populate a form from a JSON or XML body
+// Fields tagged with header, cookie or path are still fetched from their own sources, other fields only from the body.
+func (r *Request) BindBody(m interface{}) error {
+	return r.fillForm(m, true)
+}
+
+func (r *Request) fillForm(m interface{}, bodyOnly bool) error {
 	formMeta, err := InspectForm(m)
 	if err != nil {
 		return err
 	}
 
 	form := reflect.Indirect(reflect.New(*formMeta.Type))
-	for fieldName, fieldConf := range formMeta.FieldMap {
-		var v string
-		if fieldConf.FromPath {
-			v = r.PathParam(fieldConf.ParamName)
-		} else if fieldConf.FromHeader {
-			v = r.Header(fieldConf.ParamName)
-		} else if fieldConf.FromCookie {
-			v = r.Cookie(fieldConf.ParamName)
-		} else {
-			v = r.Param(fieldConf.ParamName)
-		}
-
-		if fieldConf.IsRequired && v == "" {
-			return newFormError(FormErrMissingRequired, fieldConf.ParamName, nil)
-		}
-
-		value, err := StringCast(v, fieldConf.Type)
-		if err != nil {
-			if !fieldConf.IsRequired {
-				continue
-			}
-			return newFormError(FormErrTypeCannotCast, fieldConf.ParamName, err)
-		}
-
-		fieldV := form.FieldByName(fieldName)
-		vv := reflect.ValueOf(value)
-		if fieldV.CanSet() && vv.IsValid() {
-			fieldV.Set(vv)
-		}
+	fieldErrors := make(FormErrors, 0)
+	src := &formSource{r: r, bodyOnly: bodyOnly}
+	if err := r.fillStruct(form, formMeta, "", src, &fieldErrors); err != nil {
+		return err
+	}
+	if len(fieldErrors) > 0 {
+		return fieldErrors
 	}
 
 	mV := reflect.Indirect(reflect.ValueOf(m))
@@ -137,6 +269,13 @@ func (r *Request) FillForm(m interface{}) error {
 	return nil
 }
 
+// RewritePath replace the path of the request, filters of a RouterHub call it to change the router matched
+// Body limits of the new router apply, unless params have been read before rewriting.
+func (r *Request) RewritePath(path string) {
+	r.URL.Path = path
+	r.URL.RawPath = ""
+}
+
 // SetContextValue set context value of the current request so that it can be passed down
 func (r *Request) SetContextValue(key interface{}, value interface{}) {
 	req := r.Req
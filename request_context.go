@@ -1,8 +1,10 @@
 package goweb
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -12,15 +14,21 @@ func fmtDuration(duration time.Duration) string {
 
 // RequestContext context for a request
 type RequestContext struct {
-	StartTime  time.Time
-	Method     string
-	Proto      string
-	Host       string
-	URI        string
-	RemoteAddr string
-	UserAgent  string
-	StatusCode int
-	Request    *Request
+	StartTime    time.Time
+	Method       string
+	Proto        string
+	Host         string
+	URI          string
+	RemoteAddr   string
+	UserAgent    string
+	StatusCode   int
+	ResponseSize int64 // bytes of response body written
+	Request      *Request
+	Router       *Router // the matched router, nil if no router matched
+	RequestID    string  // ID of the request, if any
+	Error        error   // error returned by the handler, or the recovered panic
+	values       map[string]interface{}
+	valuesMutex  sync.RWMutex
 }
 
 // NewRequestContext create request context from a given net/http.Request
@@ -38,6 +46,61 @@ func NewRequestContext(req *Request) *RequestContext {
 	}
 }
 
+// Route pattern of the matched router, empty if no router matched
+func (c *RequestContext) Route() string {
+	if c.Router == nil {
+		return ""
+	}
+	return c.Router.PathConfig.Domain + c.Router.PathConfig.Path
+}
+
+// Set store a value of the request under key, so that filters and handlers can share it
+func (c *RequestContext) Set(key string, value interface{}) {
+	c.valuesMutex.Lock()
+	defer c.valuesMutex.Unlock()
+	if c.values == nil {
+		c.values = make(map[string]interface{}, 0)
+	}
+	c.values[key] = value
+}
+
+// Get value stored under key, ok is false if not found
+func (c *RequestContext) Get(key string) (value interface{}, ok bool) {
+	c.valuesMutex.RLock()
+	defer c.valuesMutex.RUnlock()
+	value, ok = c.values[key]
+	return
+}
+
+// MustGet value stored under key, panics if not found
+func (c *RequestContext) MustGet(key string) interface{} {
+	value, ok := c.Get(key)
+	if !ok {
+		panic(fmt.Sprintf("request context value %q not found", key))
+	}
+	return value
+}
+
+// ContextValue value stored under key as type T, ok is false if not found or not of type T
+func ContextValue[T any](c *RequestContext, key string) (value T, ok bool) {
+	v, found := c.Get(key)
+	if !found {
+		return value, false
+	}
+	value, ok = v.(T)
+	return
+}
+
+// MustContextValue value stored under key as type T, panics if not found or not of type T
+func MustContextValue[T any](c *RequestContext, key string) T {
+	v := c.MustGet(key)
+	value, ok := v.(T)
+	if !ok {
+		panic(fmt.Sprintf("request context value %q is %T, not %T", key, v, value))
+	}
+	return value
+}
+
 // Finished test if request has finished processing
 func (c *RequestContext) Finished() bool {
 	return c.StatusCode != 0
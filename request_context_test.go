@@ -0,0 +1,61 @@
+package goweb
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type contextUser struct {
+	Name string
+}
+
+func TestRequestContextValues(t *testing.T) {
+	server := NewAppServer(&AppServerConfig{})
+	server.Use(func(next RequestHandlerFunc) RequestHandlerFunc {
+		return func(req *Request, resp *Response, ctx *RequestContext) error {
+			ctx.Set("user", &contextUser{Name: "alice"})
+			return next(req, resp, ctx)
+		}
+	})
+	server.GET("/me", func(req *Request, resp *Response, ctx *RequestContext) error {
+		user := MustContextValue[*contextUser](ctx, "user")
+		return resp.WriteString(user.Name)
+	})
+	server.prepare()
+
+	w := httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, httptest.NewRequest(HttpGet, "/me", nil))
+	assert(w.Body.String() == "alice", "value set by middleware should be read by handler")
+
+	ctx := NewRequestContext(NewRequest(httptest.NewRequest(HttpGet, "/", nil)))
+	_, ok := ctx.Get("missing")
+	assert(!ok, "missing value should not be found")
+	ctx.Set("count", 1)
+	_, ok = ContextValue[string](ctx, "count")
+	assert(!ok, "value of another type should not be found")
+	count, ok := ContextValue[int](ctx, "count")
+	assert(ok && count == 1, "typed value wrong")
+
+	assert(panics(func() { ctx.MustGet("missing") }), "MustGet should panic on missing value")
+	assert(panics(func() { MustContextValue[string](ctx, "count") }), "MustContextValue should panic on wrong type")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx.Set("count", i)
+			ctx.Get("count")
+		}(i)
+	}
+	wg.Wait()
+}
+
+func panics(fn func()) (panicked bool) {
+	defer func() {
+		panicked = recover() != nil
+	}()
+	fn()
+	return false
+}
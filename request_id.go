@@ -0,0 +1,61 @@
+package goweb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// DefaultRequestIDHeader header carrying request IDs, used if AppServerConfig.RequestIDHeader is not set
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength incoming request IDs longer than this are replaced by generated ones
+const maxRequestIDLength = 128
+
+// requestIDKey key of the request ID in context.Context of requests
+type requestIDKey struct{}
+
+// NewRequestID generate a random request ID of 32 hex digits
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RequestIDFromContext request ID stored in the context.Context of a request, empty if none
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID test if an incoming request ID is safe to be logged and echoed, only printable ASCII is allowed
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDHeader header carrying request IDs
+func (server *AppServer) requestIDHeader() string {
+	if server.RequestIDHeader == "" {
+		return DefaultRequestIDHeader
+	}
+	return server.RequestIDHeader
+}
+
+// requestID accept the request ID from the request header, or generate one if missing or invalid
+func (server *AppServer) requestID(req *Request) string {
+	if id := req.Header(server.requestIDHeader()); validRequestID(id) {
+		return id
+	}
+	if server.RequestIDGenerator != nil {
+		return server.RequestIDGenerator()
+	}
+	return NewRequestID()
+}
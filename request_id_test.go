@@ -0,0 +1,68 @@
+package goweb
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	var logged string
+	server := NewAppServer(&AppServerConfig{
+		LogHandlerFunc: func(ctx *RequestContext) {
+			logged = ctx.RequestID
+		},
+	})
+	server.GET("/id", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return resp.WriteString(RequestIDFromContext(req.Req.Context()))
+	})
+	server.GET("/fail", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return NewHTTPError(400, "bad")
+	})
+	server.prepare()
+
+	req := httptest.NewRequest(HttpGet, "/id", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	w := httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, req)
+	assert(w.Body.String() == "abc-123" && w.Header().Get("X-Request-ID") == "abc-123" && logged == "abc-123", "incoming request ID should be accepted")
+
+	w = httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, httptest.NewRequest(HttpGet, "/id", nil))
+	id := w.Header().Get("X-Request-ID")
+	assert(len(id) == 32 && w.Body.String() == id && logged == id, "request ID should be generated")
+
+	req = httptest.NewRequest(HttpGet, "/id", nil)
+	req.Header.Set("X-Request-ID", "bad id\x01"+strings.Repeat("x", 200))
+	w = httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, req)
+	assert(len(w.Header().Get("X-Request-ID")) == 32, "invalid request ID should be replaced")
+
+	w = httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, httptest.NewRequest(HttpGet, "/fail", nil))
+	body := make(map[string]interface{}, 0)
+	assert(json.Unmarshal(w.Body.Bytes(), &body) == nil, "error body invalid")
+	assert(body["request_id"] == w.Header().Get("X-Request-ID"), "error body should contain request ID")
+}
+
+func TestRequestIDConfig(t *testing.T) {
+	server := NewAppServer(&AppServerConfig{
+		RequestIDHeader:    "X-Correlation-ID",
+		RequestIDGenerator: func() string { return "generated" },
+	})
+	server.GET("/id", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return resp.WriteString(ctx.RequestID)
+	})
+	server.prepare()
+
+	w := httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, httptest.NewRequest(HttpGet, "/id", nil))
+	assert(w.Body.String() == "generated" && w.Header().Get("X-Correlation-ID") == "generated", "custom generator should be used")
+
+	req := httptest.NewRequest(HttpGet, "/id", nil)
+	req.Header.Set("X-Correlation-ID", "from-client")
+	w = httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, req)
+	assert(w.Body.String() == "from-client" && w.Header().Get("X-Request-ID") == "", "custom header should be used")
+}
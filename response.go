@@ -1,31 +1,113 @@
 package goweb
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"encoding/xml"
 	"html/template"
+	"net"
 	"net/http"
 )
 
 // Response encapsulate http.ResponseWriter object to provide a simple api
 // Original http.ResponseWriter can be accessed via Writer field.
+// Response implements http.ResponseWriter, http.Flusher, http.Hijacker and http.Pusher,
+// the status code and bytes written are recorded in Context.
 type Response struct {
-	Writer  http.ResponseWriter
-	Context *RequestContext
+	Writer      http.ResponseWriter
+	Context     *RequestContext
+	templates   *TemplateEngine
+	wroteHeader bool
+	hijacked    bool
 }
 
-// WriteHeader write http response code
+// WriteHeader write http response code, only the first call takes effect
+// Informational codes other than 101 (eg: 103 Early Hints) are sent as they are, the final code is written later.
 func (resp *Response) WriteHeader(statusCode int) {
+	if resp.wroteHeader || resp.hijacked {
+		return
+	}
+	if isInformational(statusCode) {
+		resp.Writer.WriteHeader(statusCode)
+		return
+	}
+	resp.wroteHeader = true
 	resp.Context.StatusCode = statusCode
 	resp.Writer.WriteHeader(statusCode)
 }
 
+// isInformational test if the status is a 1xx code which is followed by a final one, 101 Switching Protocols is final
+func isInformational(statusCode int) bool {
+	return statusCode >= 100 && statusCode < 200 && statusCode != http.StatusSwitchingProtocols
+}
+
+// Written test if the header has been written
+func (resp *Response) Written() bool {
+	return resp.wroteHeader
+}
+
+// Size bytes of body written, the Compress middleware replaces it with compressed bytes sent when the handler returns
+func (resp *Response) Size() int64 {
+	return resp.Context.ResponseSize
+}
+
 // Write write http body data
 func (resp *Response) Write(data []byte) (int, error) {
-	if resp.Context.StatusCode == 0 {
+	if resp.hijacked {
+		return 0, http.ErrHijacked
+	}
+	if !resp.wroteHeader {
+		resp.WriteHeader(http.StatusOK)
+	}
+	n, err := resp.Writer.Write(data)
+	resp.Context.ResponseSize += int64(n)
+	return n, err
+}
+
+// Flush send buffered data to the client, it does nothing if the underlying writer can not flush
+func (resp *Response) Flush() {
+	if resp.hijacked {
+		return
+	}
+	if !resp.wroteHeader {
 		resp.WriteHeader(http.StatusOK)
 	}
-	return resp.Writer.Write(data)
+	http.NewResponseController(resp.Writer).Flush()
+}
+
+// Hijack take over the connection, http.ErrNotSupported is returned if the underlying writer does not support it
+// The response is finished after hijacking, with status code 101 recorded if nothing was written.
+func (resp *Response) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(resp.Writer).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	resp.hijacked = true
+	if resp.Context.StatusCode == 0 {
+		resp.Context.StatusCode = http.StatusSwitchingProtocols
+	}
+	return conn, rw, nil
+}
+
+// Push initiate an HTTP/2 server push, http.ErrNotSupported is returned if the underlying writer does not support it
+func (resp *Response) Push(target string, opts *http.PushOptions) error {
+	w := resp.Writer
+	for {
+		switch ww := w.(type) {
+		case http.Pusher:
+			return ww.Push(target, opts)
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = ww.Unwrap()
+		default:
+			return http.ErrNotSupported
+		}
+	}
+}
+
+// Unwrap the underlying writer, for http.ResponseController
+func (resp *Response) Unwrap() http.ResponseWriter {
+	return resp.Writer
 }
 
 // WriteString write a string as http response body
@@ -55,16 +137,63 @@ func (resp *Response) WriteJSON(data interface{}) error {
 // WriteXML write a XML string as response body
 // param data will be encoded as xml string automatically
 func (resp *Response) WriteXML(data interface{}) error {
-	resp.Header().Set("Content-Type", "text/xml; charset=utf-8")
-	enc := xml.NewEncoder(resp)
-	err := enc.Encode(data)
+	xmlData, err := xml.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if resp.Header().Get("Content-Type") == "" {
+		resp.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	}
+	if resp.Context.StatusCode == 0 {
+		resp.WriteHeader(http.StatusOK)
+	}
+	_, err = resp.Write(xmlData)
 	return err
 }
 
 // RenderTemplate render a set of templates with data as the context
+// Files are parsed on every call, use Render with a TemplateEngine to cache parsed templates.
 func (resp *Response) RenderTemplate(data interface{}, tpls ...string) error {
-	tmpl := template.Must(template.ParseFiles(tpls...))
-	return tmpl.Execute(resp.Writer, data)
+	tmpl, err := template.ParseFiles(tpls...)
+	if err != nil {
+		return err
+	}
+	return resp.writeView(http.StatusOK, &TemplateView{Template: tmpl, Data: data})
+}
+
+// Render render a page of the TemplateEngine of the server as HTML, with the default layout
+func (resp *Response) Render(status int, name string, data interface{}) error {
+	if resp.templates == nil {
+		return ErrNoTemplateEngine
+	}
+	view, err := resp.templates.View(name, data)
+	if err != nil {
+		return err
+	}
+	return resp.writeView(status, view)
+}
+
+// View get a view of a page of the TemplateEngine of the server, which can be rendered by Negotiate
+func (resp *Response) View(name string, data interface{}) (*TemplateView, error) {
+	if resp.templates == nil {
+		return nil, ErrNoTemplateEngine
+	}
+	return resp.templates.View(name, data)
+}
+
+// writeView execute the view into a buffer, and write it as HTML if it succeeds
+func (resp *Response) writeView(status int, view *TemplateView) error {
+	buf := &bytes.Buffer{}
+	if err := view.render(buf); err != nil {
+		return err
+	}
+	if resp.Header().Get("Content-Type") == "" {
+		resp.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	resp.WriteHeader(status)
+	_, err := resp.Write(buf.Bytes())
+	return err
 }
 
 // Header get HTTP header
@@ -75,10 +204,10 @@ func (resp *Response) Header() http.Header {
 
 // NotFound shorthand for return 404
 func (resp *Response) NotFound() {
-	http.NotFound(resp.Writer, resp.Context.Request.Req)
+	http.NotFound(resp, resp.Context.Request.Req)
 }
 
 // Redirect redirect to a given url
 func (resp *Response) Redirect(url string, code int) {
-	http.Redirect(resp.Writer, resp.Context.Request.Req, url, code)
+	http.Redirect(resp, resp.Context.Request.Req, url, code)
 }
@@ -0,0 +1,103 @@
+package goweb
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testResponse() (*Response, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	req := NewRequest(httptest.NewRequest(HttpGet, "/", nil))
+	return &Response{Writer: w, Context: NewRequestContext(req)}, w
+}
+
+func TestResponseWriter(t *testing.T) {
+	resp, w := testResponse()
+	assert(!resp.Written(), "header should not be written yet")
+	resp.WriteHeader(201)
+	resp.WriteHeader(500)
+	assert(resp.Written() && w.Code == 201 && resp.Context.StatusCode == 201, "only the first WriteHeader should take effect")
+
+	resp.WriteString("hello")
+	resp.Write([]byte(" world"))
+	assert(resp.Size() == 11 && resp.Context.ResponseSize == 11, "bytes written wrong")
+
+	resp.Flush()
+	assert(w.Flushed, "Flush should reach the underlying writer")
+
+	_, _, err := resp.Hijack()
+	assert(errors.Is(err, http.ErrNotSupported), "Hijack should not be supported by a recorder")
+	assert(errors.Is(resp.Push("/app.js", nil), http.ErrNotSupported), "Push should not be supported by a recorder")
+
+	var _ http.Flusher = resp
+	var _ http.Hijacker = resp
+	var _ http.Pusher = resp
+}
+
+// statusRecorder record every code written, httptest.ResponseRecorder keeps only the first one
+type statusRecorder struct {
+	*httptest.ResponseRecorder
+	codes []int
+}
+
+func (w *statusRecorder) WriteHeader(statusCode int) {
+	w.codes = append(w.codes, statusCode)
+	if statusCode >= 200 || statusCode == http.StatusSwitchingProtocols {
+		w.ResponseRecorder.WriteHeader(statusCode)
+	}
+}
+
+func TestResponseInformational(t *testing.T) {
+	w := &statusRecorder{ResponseRecorder: httptest.NewRecorder()}
+	req := NewRequest(httptest.NewRequest(HttpGet, "/", nil))
+	resp := &Response{Writer: w, Context: NewRequestContext(req)}
+	resp.Header().Set("Link", "</app.css>; rel=preload")
+	resp.WriteHeader(http.StatusEarlyHints)
+	assert(!resp.Written() && resp.Context.StatusCode == 0, "informational code should not be final")
+	resp.WriteHeader(http.StatusOK)
+	assert(resp.Written() && resp.Context.StatusCode == 200 && len(w.codes) == 2 && w.codes[0] == 103 && w.Code == 200, "final code should follow informational one")
+
+	server := NewAppServer(&AppServerConfig{})
+	server.Use(Compress(&CompressConfig{MinSize: -1}))
+	server.GET("/hints", func(req *Request, resp *Response, ctx *RequestContext) error {
+		resp.WriteHeader(http.StatusEarlyHints)
+		return resp.WriteString("hello")
+	})
+	server.prepare()
+	w = &statusRecorder{ResponseRecorder: httptest.NewRecorder()}
+	r := httptest.NewRequest(HttpGet, "/hints", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	server.ServeMux.ServeHTTP(w, r)
+	assert(len(w.codes) == 2 && w.codes[0] == 103 && w.Code == 200 && w.Header().Get("Content-Encoding") == "gzip", "informational code should pass through Compress")
+}
+
+func TestResponseHijack(t *testing.T) {
+	server := NewAppServer(&AppServerConfig{})
+	server.Use(Compress(nil))
+	server.GET("/hijack", func(req *Request, resp *Response, ctx *RequestContext) error {
+		conn, rw, err := resp.Hijack()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 6\r\nConnection: close\r\n\r\nraw ok")
+		rw.Flush()
+		// writes after hijacking are refused
+		if _, err := resp.Write([]byte("more")); !errors.Is(err, http.ErrHijacked) {
+			return errors.New("write after hijack should fail")
+		}
+		return nil
+	})
+	server.prepare()
+	ts := httptest.NewServer(server.ServeMux)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/hijack")
+	assert(err == nil, "hijacked request failed")
+	defer res.Body.Close()
+	line, _ := bufio.NewReader(res.Body).ReadString('\n')
+	assert(res.StatusCode == 200 && line == "raw ok", "hijacked response wrong")
+}
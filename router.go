@@ -1,8 +1,25 @@
 package goweb
 
+import (
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+)
+
 // RouterConfig config for a router
+// MaxMemory bytes of a multipart form kept in memory, the rest of files are stored in temporary files, DefaultMaxMemory if 0.
+// MaxBodySize limits bytes read from the request body, requests with a larger body are rejected with 413, 0 means no limit.
+// StreamMultipart leaves multipart bodies unparsed, handlers read parts one by one with Request.MultipartReader or Request.EachPart.
+// AccessLog selects fields and sampling of access logs, see AccessLogConfig.
+// Filters run before filters added by Router.AddFilter, see Filter.
 type RouterConfig struct {
 	DisableAccessLog bool
+	MaxMemory        int64
+	MaxBodySize      int64
+	StreamMultipart  bool
+	AccessLog        *AccessLogConfig
+	Filters          []*Filter
 }
 
 var DefaultRouterConfig *RouterConfig
@@ -12,23 +29,135 @@ func init() {
 }
 
 // Router represent a router rule
+// Method is the HTTP method this router serves, empty Method means any method.
+// Name describes the handler, it is the name of the handler function if not set.
 type Router struct {
+	Method      string
+	Name        string
 	HandlerFunc RequestHandlerFunc
 	PathConfig  *PathConfig
 	Config      *RouterConfig
+	middlewares []Middleware
+	filters     []*Filter
+	handler     RequestHandlerFunc // HandlerFunc wrapped by filters and middlewares
+	handlerOnce sync.Once
+}
+
+// Use add middlewares which wrap the handler of this router, it must be called before the router serves requests
+func (r *Router) Use(middlewares ...Middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// AddFilter add filters which run right before the handler of this router, see Filter
+// It must be called before the router serves requests.
+func (r *Router) AddFilter(filters ...*Filter) {
+	r.filters = append(r.filters, filters...)
 }
 
 // HandleRequest implements the standard HandlerFunc interface
+// Params of the request are parsed according to the config of this router before calling the handler.
 func (r *Router) HandleRequest(req *Request, resp *Response, ctx *RequestContext) error {
-	return r.HandlerFunc(req, resp, ctx)
+	if ctx != nil {
+		ctx.Router = r
+	}
+	if err := req.prepare(resp.Writer, r.Config); err != nil {
+		return err
+	}
+	r.handlerOnce.Do(r.compose)
+	return r.handler(req, resp, ctx)
+}
+
+// resolveRoute a router mounted directly to the server handles every request passed to it
+func (r *Router) resolveRoute(req *Request) *Router {
+	return r
 }
 
-// NewRouter create a router object
+// compose wrap HandlerFunc with filters of the config and the router, then middlewares
+func (r *Router) compose() {
+	filters := r.filters
+	if r.Config != nil && len(r.Config.Filters) > 0 {
+		filters = append(append(make([]*Filter, 0, len(r.Config.Filters)+len(r.filters)), r.Config.Filters...), r.filters...)
+	}
+	r.handler = applyMiddlewares(withFilters(filters, r.HandlerFunc), r.middlewares)
+}
+
+func (r *Router) String() string {
+	method := r.Method
+	if method == "" {
+		method = "*"
+	}
+	return method + " " + r.PathConfig.Domain + r.PathConfig.Path
+}
+
+// Info describe this router
+func (r *Router) Info() RouteInfo {
+	name := r.Name
+	if name == "" && r.HandlerFunc != nil {
+		if f := runtime.FuncForPC(reflect.ValueOf(r.HandlerFunc).Pointer()); f != nil {
+			name = f.Name()
+		}
+	}
+	return RouteInfo{
+		Method:  r.Method,
+		Pattern: r.PathConfig.Domain + r.PathConfig.Path,
+		Handler: name,
+	}
+}
+
+// RouteInfo description of a registered router, useful for debugging
+type RouteInfo struct {
+	Method  string // empty Method means any method
+	Pattern string
+	Handler string
+}
+
+func (info RouteInfo) String() string {
+	method := info.Method
+	if method == "" {
+		method = "*"
+	}
+	return method + "\t" + info.Pattern + "\t" + info.Handler
+}
+
+// sortRouteInfos sort routes by pattern and method
+func sortRouteInfos(infos []RouteInfo) {
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Pattern != infos[j].Pattern {
+			return infos[i].Pattern < infos[j].Pattern
+		}
+		return infos[i].Method < infos[j].Method
+	})
+}
+
+// NewRouter create a router object serves any HTTP method
 func NewRouter(pattern string, handlerFunc RequestHandlerFunc, config *RouterConfig) *Router {
+	return NewMethodRouter("", pattern, handlerFunc, config)
+}
+
+// NewMethodRouter create a router object serves the given HTTP method
+func NewMethodRouter(method string, pattern string, handlerFunc RequestHandlerFunc, config *RouterConfig) *Router {
+	if config == nil {
+		config = DefaultRouterConfig
+	}
 	pathConfig := ParsePathParam(pattern)
 	return &Router{
+		Method:      method,
 		HandlerFunc: handlerFunc,
 		PathConfig:  pathConfig,
 		Config:      config,
 	}
 }
+
+// allowedMethods sort registered methods for the Allow header, HEAD is implied by GET
+func allowedMethods(methods []string) []string {
+	hasGet, hasHead := false, false
+	for _, method := range methods {
+		hasGet = hasGet || method == HttpGet
+		hasHead = hasHead || method == HttpHead
+	}
+	if hasGet && !hasHead {
+		methods = append(methods, HttpHead)
+	}
+	sort.Strings(methods)
+	return methods
+}
@@ -2,12 +2,20 @@ package goweb
 
 import (
 	"net/http"
+	"sync"
 )
 
+// routeResolver a RequestHandler which knows the router a request will be passed to, nil if none
+type routeResolver interface {
+	resolveRoute(req *Request) *Router
+}
+
 type RouterAdapter struct {
 	RequestHandler   RequestHandler
 	AppServer        *AppServer
 	DisableAccessLog bool
+	handler          RequestHandlerFunc // RequestHandler wrapped by middlewares of the server
+	handlerOnce      sync.Once
 }
 
 func (r *RouterAdapter) HandleError(err error, resp *Response, context *RequestContext) {
@@ -16,38 +24,81 @@ func (r *RouterAdapter) HandleError(err error, resp *Response, context *RequestC
 	}
 }
 
+// HandleLog write the access log, unless disabled or not sampled by the config of the matched router
 func (r *RouterAdapter) HandleLog(context *RequestContext) {
 	if r.DisableAccessLog {
 		return
 	}
+	if router := context.Router; router != nil && router.Config != nil {
+		if router.Config.DisableAccessLog || !router.Config.AccessLog.sampled(context) {
+			return
+		}
+	}
 
 	if r.AppServer.LogHandlerFunc != nil {
 		r.AppServer.LogHandlerFunc(context)
 	}
 }
 
+// handlePanic pass a recovered panic to the error handler, respond 500 if nothing was written
+func (r *RouterAdapter) handlePanic(recovered interface{}, resp *Response, context *RequestContext) {
+	defer func() {
+		// the error handler itself panics, nothing more can be done than responding 500
+		if recover() != nil && !context.Finished() {
+			resp.WriteHeader(http.StatusInternalServerError)
+		}
+	}()
+
+	context.Error = newPanicError(recovered)
+	r.HandleError(context.Error, resp, context)
+	if !context.Finished() {
+		resp.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
 func (r *RouterAdapter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.AppServer.activeRequests.Add(1)
+	defer r.AppServer.activeRequests.Done()
+
 	theRequest := NewRequest(req)
+	requestID := r.AppServer.requestID(theRequest)
+	theRequest.SetContextValue(requestIDKey{}, requestID)
 	context := NewRequestContext(theRequest)
+	context.RequestID = requestID
+	w.Header().Set(r.AppServer.requestIDHeader(), requestID)
 	resp := &Response{
-		Writer:  w,
-		Context: context,
+		Writer:    w,
+		Context:   context,
+		templates: r.AppServer.Templates,
 	}
 
-	err := theRequest.ParseParam()
-	if err != nil {
-		r.HandleError(err, resp, context)
-	}
-
-	if context.Finished() {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if recovered == http.ErrAbortHandler {
+				r.HandleLog(context)
+				panic(recovered)
+			}
+			r.handlePanic(recovered, resp, context)
+		}
 		r.HandleLog(context)
-		return
-	}
+	}()
 
-	err = r.RequestHandler.HandleRequest(theRequest, resp, context)
+	// params are parsed by the matched router, see Router.HandleRequest
+	r.handlerOnce.Do(func() {
+		r.handler = applyMiddlewares(r.RequestHandler.HandleRequest, r.AppServer.middlewares)
+	})
+	// body limits apply before middlewares and filters, they may read params before routing
+	var err error
+	if resolver, ok := r.RequestHandler.(routeResolver); ok {
+		if router := resolver.resolveRoute(theRequest); router != nil {
+			err = theRequest.applyConfig(w, router.Config)
+		}
+	}
+	if err == nil {
+		err = r.handler(theRequest, resp, context)
+	}
 	if err != nil {
+		context.Error = err
 		r.HandleError(err, resp, context)
 	}
-
-	r.HandleLog(context)
 }
@@ -0,0 +1,63 @@
+package goweb
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+)
+
+type panicController struct{}
+
+func (c *panicController) Get(req *Request, resp *Response, ctx *RequestContext) *JSONResponse {
+	var m map[string]int
+	m["boom"] = 1
+	return nil
+}
+
+func TestRecoverPanic(t *testing.T) {
+	var handledErr error
+	logged := 0
+	server := NewAppServer(&AppServerConfig{
+		ErrorHandlerFunc: func(err error, resp *Response, ctx *RequestContext) {
+			handledErr = err
+		},
+		LogHandlerFunc: func(ctx *RequestContext) {
+			logged++
+		},
+	})
+	server.AddRouter("/panic", func(req *Request, resp *Response, ctx *RequestContext) error {
+		panic("boom")
+	}, nil)
+	server.AddController("/controller", &panicController{}, map[string]string{HttpGet: "Get"})
+	server.prepare()
+
+	w := httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, httptest.NewRequest(HttpGet, "/panic", nil))
+	assert(w.Code == 500, "panic should respond 500")
+	assert(logged == 1, "access log should be emitted on panic")
+	pe, ok := handledErr.(*PanicError)
+	assert(ok && pe.Value == "boom", "panic should be passed to error handler")
+	assert(bytes.Contains(pe.Stack, []byte("TestRecoverPanic")), "panic stack trace missing")
+
+	w = httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, httptest.NewRequest(HttpGet, "/controller", nil))
+	pe, ok = handledErr.(*PanicError)
+	assert(w.Code == 500 && ok && pe.Unwrap() != nil, "controller panic should be recovered")
+	assert(logged == 2, "access log should be emitted on controller panic")
+}
+
+func TestRecoverPanicErrorHandlerResponse(t *testing.T) {
+	server := NewAppServer(&AppServerConfig{
+		ErrorHandlerFunc: func(err error, resp *Response, ctx *RequestContext) {
+			resp.WriteHeader(503)
+		},
+	})
+	server.AddRouter("/panic", func(req *Request, resp *Response, ctx *RequestContext) error {
+		panic("boom")
+	}, nil)
+	server.prepare()
+
+	w := httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, httptest.NewRequest(HttpGet, "/panic", nil))
+	assert(w.Code == 503, "response of error handler should be kept")
+}
@@ -1,6 +1,12 @@
 package goweb
 
-import "errors"
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
 
 type RequestFilterWrapper struct {
 	f RequestFilterFunc
@@ -15,80 +21,186 @@ func NewRequestFilter(f RequestFilterFunc) RequestFilter {
 }
 
 // RouterHub a hub for a group of routers which share the same configuration
+// Patterns of routers in a hub must start with BasePattern.
+// Routers are stored in a prefix tree, static path segments always take precedence over path params.
 type RouterHub struct {
-	BasePattern        string
-	requestFilters     []RequestFilter
-	PathDepthRouterMap map[int]([]*Router)
+	BasePattern   string
+	filters       []*Filter
+	middlewares   []Middleware
+	tree          *routeNode
+	routers       []*Router
+	extraPatterns []string
+	handler       RequestHandlerFunc // routing wrapped by filters and middlewares
+	handlerOnce   sync.Once
 }
 
 // NewRouterHub create a new routerhub
 func NewRouterHub(basePattern string) *RouterHub {
 	return &RouterHub{
-		BasePattern:        basePattern,
-		requestFilters:     make([]RequestFilter, 0),
-		PathDepthRouterMap: make(map[int]([]*Router), 0),
+		BasePattern: basePattern,
+		filters:     make([]*Filter, 0),
+		tree:        &routeNode{},
 	}
 }
 
 // AddRouter add router to the hub
+// It panics if the router pattern conflicts with a registered one.
 func (rh *RouterHub) AddRouter(r *Router) {
-	if r.PathConfig.PatternString() != rh.BasePattern {
+	if !strings.HasPrefix(r.PathConfig.PatternString(), rh.BasePattern) {
 		panic(errors.New("Router hub base pattern not match"))
 	}
-	dp := r.PathConfig.PathDepth
-	list, found := rh.PathDepthRouterMap[dp]
-	if !found {
-		list = make([]*Router, 0)
+	if err := rh.tree.addRoute(r); err != nil {
+		panic(errors.WithMessage(err, r.PathConfig.Domain+r.PathConfig.Path))
+	}
+	rh.routers = append(rh.routers, r)
+	// an optional first param makes the base path without trailing slash a valid path
+	if params := r.PathConfig.Params; len(params) > 0 && params[0].Optional && r.PathConfig.BasePath != "/" {
+		rh.addExtraPattern(strings.TrimSuffix(r.PathConfig.PatternString(), "/"))
+	}
+}
+
+// Routes list routers registered to this hub
+func (rh *RouterHub) Routes() []RouteInfo {
+	infos := make([]RouteInfo, 0, len(rh.routers))
+	for _, r := range rh.routers {
+		infos = append(infos, r.Info())
 	}
-	list = append(list, r)
-	rh.PathDepthRouterMap[dp] = list
+	sortRouteInfos(infos)
+	return infos
+}
+
+func (rh *RouterHub) addExtraPattern(pattern string) {
+	for _, p := range rh.extraPatterns {
+		if p == pattern {
+			return
+		}
+	}
+	rh.extraPatterns = append(rh.extraPatterns, pattern)
 }
 
 // AddController add controller to the hub
+// If methodMap is nil, methods named after HTTP methods are registered, see ConventionMethodMap.
 func (rh *RouterHub) AddController(pattern string, ins interface{}, methodMap map[string]string) {
-	c, err := WrapController(ins, methodMap)
+	routers, err := controllerRouters(pattern, ins, methodMap, DefaultRouterConfig)
+	if err != nil {
+		panic(err)
+	}
+	for _, router := range routers {
+		rh.AddRouter(router)
+	}
+}
+
+// AddResource add a REST style resource controller to the hub
+// Collection routes are registered at pattern, member routes at pattern/:id, see resourceRouters for conventions.
+func (rh *RouterHub) AddResource(pattern string, ins interface{}) {
+	routers, err := resourceRouters(pattern, ins, DefaultRouterConfig)
 	if err != nil {
 		panic(err)
 	}
-	router := NewRouter(pattern, c, DefaultRouterConfig)
-	rh.AddRouter(router)
+	for _, router := range routers {
+		rh.AddRouter(router)
+	}
+}
+
+// HandleMethod register a handler for the given HTTP method and pattern, the router is returned for Router.Use and Router.AddFilter
+func (rh *RouterHub) HandleMethod(method string, pattern string, handlerFunc RequestHandlerFunc) *Router {
+	r := NewMethodRouter(method, pattern, handlerFunc, DefaultRouterConfig)
+	rh.AddRouter(r)
+	return r
+}
+
+// GET register a handler for GET requests, HEAD requests are also served if no HEAD handler registered
+func (rh *RouterHub) GET(pattern string, handlerFunc RequestHandlerFunc) *Router {
+	return rh.HandleMethod(HttpGet, pattern, handlerFunc)
+}
+
+// POST register a handler for POST requests
+func (rh *RouterHub) POST(pattern string, handlerFunc RequestHandlerFunc) *Router {
+	return rh.HandleMethod(HttpPost, pattern, handlerFunc)
 }
 
-// AddRequestFilter add new request filter to the hub
+// PUT register a handler for PUT requests
+func (rh *RouterHub) PUT(pattern string, handlerFunc RequestHandlerFunc) *Router {
+	return rh.HandleMethod(HttpPut, pattern, handlerFunc)
+}
+
+// PATCH register a handler for PATCH requests
+func (rh *RouterHub) PATCH(pattern string, handlerFunc RequestHandlerFunc) *Router {
+	return rh.HandleMethod(HttpPatch, pattern, handlerFunc)
+}
+
+// DELETE register a handler for DELETE requests
+func (rh *RouterHub) DELETE(pattern string, handlerFunc RequestHandlerFunc) *Router {
+	return rh.HandleMethod(HttpDelete, pattern, handlerFunc)
+}
+
+// OPTIONS register a handler for OPTIONS requests
+func (rh *RouterHub) OPTIONS(pattern string, handlerFunc RequestHandlerFunc) *Router {
+	return rh.HandleMethod(HttpOptions, pattern, handlerFunc)
+}
+
+// HEAD register a handler for HEAD requests
+func (rh *RouterHub) HEAD(pattern string, handlerFunc RequestHandlerFunc) *Router {
+	return rh.HandleMethod(HttpHead, pattern, handlerFunc)
+}
+
+// AddRequestFilter add new request filter to the hub, it runs as the before phase of a Filter
 func (rh *RouterHub) AddRequestFilter(filter RequestFilter) {
-	rh.requestFilters = append(rh.requestFilters, filter)
+	rh.filters = append(rh.filters, requestFilterAdapter(filter))
+}
+
+// AddFilter add filters to the hub, they run before routing, see Filter
+// Filters and middlewares must be added before the hub serves requests.
+func (rh *RouterHub) AddFilter(filters ...*Filter) {
+	rh.filters = append(rh.filters, filters...)
+}
+
+// Use add middlewares which wrap request filters and routing of this hub
+func (rh *RouterHub) Use(middlewares ...Middleware) {
+	rh.middlewares = append(rh.middlewares, middlewares...)
 }
 
 // HandleRequest implements the standard HandlerFunc interface
 func (rh *RouterHub) HandleRequest(req *Request, resp *Response, ctx *RequestContext) error {
-	// apply request filters
-	if len(rh.requestFilters) > 0 {
-		for _, filter := range rh.requestFilters {
-			// check error in filters
-			if err := filter.FilterRequest(resp, ctx); err != nil {
-				return err
-			}
-
-			// if one filter has send response to the client, the whole process is done.
-			if ctx.Finished() {
-				return nil
-			}
-		}
+	rh.handlerOnce.Do(func() {
+		rh.handler = applyMiddlewares(withFilters(rh.filters, rh.route), rh.middlewares)
+	})
+	return rh.handler(req, resp, ctx)
+}
+
+// match look up the router matching the path and method of the request
+// The result is kept with the request and reused, unless the path has been rewritten since.
+func (rh *RouterHub) match(req *Request) *routeMatch {
+	if m := req.match; m != nil && m.hub == rh && m.path == req.URL.Path {
+		return m
 	}
+	m := &routeMatch{hub: rh, path: req.URL.Path, lookup: routeLookup{method: req.Req.Method}}
+	m.router = rh.tree.find(m.path, &m.lookup)
+	req.match = m
+	return m
+}
+
+// resolveRoute the router matching the path and method of the request, nil if none matches
+func (rh *RouterHub) resolveRoute(req *Request) *Router {
+	return rh.match(req).router
+}
 
-	path := req.URL.Path
-	pd := PathDepth(path)
-
-	list, found := rh.PathDepthRouterMap[pd]
-	if found {
-		for _, router := range list {
-			match, params := router.PathConfig.Match(path)
-			if match {
-				req.pathParam = params
-				router.HandleRequest(req, resp, ctx)
-				return nil
-			}
+// route pass the request to the router matching its path
+func (rh *RouterHub) route(req *Request, resp *Response, ctx *RequestContext) error {
+	m := rh.match(req)
+	lk := &m.lookup
+	if router := m.router; router != nil {
+		if len(lk.params) > 0 {
+			req.pathParam = lk.params.Map()
 		}
+		return router.HandleRequest(req, resp, ctx)
+	}
+
+	// Path matched but method not allowed
+	if lk.matched != nil {
+		resp.Header().Set("Allow", strings.Join(lk.matched.allowedMethods(), ", "))
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
 	}
 
 	// No Match
@@ -1,8 +1,10 @@
 package goweb
 
 import (
-	"log"
+	"context"
+	"net"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -12,17 +14,33 @@ type AppServer struct {
 	ServeMux             *http.ServeMux
 	LogHandlerFunc       LogHandlerFunc
 	ErrorHandlerFunc     ErrorHandlerFunc
+	ShutdownTimeout      time.Duration
+	Templates            *TemplateEngine // renders templates by name for Response.Render
+	RequestIDHeader      string          // header carrying request IDs, DefaultRequestIDHeader if empty
+	RequestIDGenerator   func() string   // generates IDs of requests without a valid one, NewRequestID if nil
 	basePatternRouterMap map[string]([]*Router)
+	routers              []*Router
+	hubs                 []*RouterHub
+	middlewares          []Middleware
+	activeRequests       sync.WaitGroup
+	shutdownHooks        []func()
+	hooksMutex           sync.Mutex
+	listener             net.Listener
+	listenerMutex        sync.Mutex
 }
 
 // AppServerConfig config structure for AppServer
 type AppServerConfig struct {
-	Addr             string        // Address this app server listens to, eg: :80
-	ReadTimeout      time.Duration // ReadTimeout for request header
-	WriteTimeout     time.Duration // WriteTimeout for response
-	MaxHeaderBytes   int           // Max Number of bytes of the request header
-	LogHandlerFunc   LogHandlerFunc
-	ErrorHandlerFunc ErrorHandlerFunc
+	Addr               string        // Address this app server listens to, eg: :80
+	ReadTimeout        time.Duration // ReadTimeout for request header
+	WriteTimeout       time.Duration // WriteTimeout for response
+	MaxHeaderBytes     int           // Max Number of bytes of the request header
+	ShutdownTimeout    time.Duration // Max time Run waits for active requests to finish on shutdown
+	LogHandlerFunc     LogHandlerFunc
+	ErrorHandlerFunc   ErrorHandlerFunc // DefaultErrorHandler is used if not set
+	Templates          *TemplateEngine  // templates rendered by Response.Render, see NewTemplateEngine
+	RequestIDHeader    string           // header carrying request IDs, DefaultRequestIDHeader if empty
+	RequestIDGenerator func() string    // generates IDs of requests without a valid one, NewRequestID if nil
 }
 
 // NewAppServer create a new AppServer instance
@@ -35,11 +53,19 @@ func NewAppServer(config *AppServerConfig) *AppServer {
 		WriteTimeout:   config.WriteTimeout,
 		MaxHeaderBytes: config.MaxHeaderBytes,
 	}
+	errorHandlerFunc := config.ErrorHandlerFunc
+	if errorHandlerFunc == nil {
+		errorHandlerFunc = DefaultErrorHandler
+	}
 	appServer := &AppServer{
 		Server:               server,
 		ServeMux:             mux,
 		LogHandlerFunc:       config.LogHandlerFunc,
-		ErrorHandlerFunc:     config.ErrorHandlerFunc,
+		ErrorHandlerFunc:     errorHandlerFunc,
+		ShutdownTimeout:      config.ShutdownTimeout,
+		Templates:            config.Templates,
+		RequestIDHeader:      config.RequestIDHeader,
+		RequestIDGenerator:   config.RequestIDGenerator,
 		basePatternRouterMap: make(map[string]([]*Router), 0),
 	}
 	return appServer
@@ -56,30 +82,120 @@ func (server *AppServer) ResponseText(url string, responseStr string) {
 }
 
 // AddController register controller to this AppServer
+// If methodMap is nil, methods named after HTTP methods are registered, see ConventionMethodMap.
 func (server *AppServer) AddController(pattern string, ins interface{}, methodMap map[string]string) {
-	c, err := WrapController(ins, methodMap)
+	routers, err := controllerRouters(pattern, ins, methodMap, &RouterConfig{
+		DisableAccessLog: false,
+	})
 	if err != nil {
 		panic(err)
 	}
-	server.AddRouter(pattern, c, &RouterConfig{
+	for _, router := range routers {
+		server.addRouter(router)
+	}
+}
+
+// AddResource register a REST style resource controller to this AppServer
+// Collection routes are registered at pattern, member routes at pattern/:id, see resourceRouters for conventions.
+func (server *AppServer) AddResource(pattern string, ins interface{}) {
+	routers, err := resourceRouters(pattern, ins, &RouterConfig{
 		DisableAccessLog: false,
 	})
+	if err != nil {
+		panic(err)
+	}
+	for _, router := range routers {
+		server.addRouter(router)
+	}
+}
+
+// Routes list routers registered to this AppServer and its hubs
+func (server *AppServer) Routes() []RouteInfo {
+	infos := make([]RouteInfo, 0, len(server.routers))
+	for _, r := range server.routers {
+		infos = append(infos, r.Info())
+	}
+	for _, hub := range server.hubs {
+		infos = append(infos, hub.Routes()...)
+	}
+	sortRouteInfos(infos)
+	return infos
+}
+
+// Use add middlewares which wrap every request handled by this server
+func (server *AppServer) Use(middlewares ...Middleware) {
+	server.middlewares = append(server.middlewares, middlewares...)
 }
 
 // AddHub add router hub to the server
 func (server *AppServer) AddHub(hub *RouterHub) {
+	server.hubs = append(server.hubs, hub)
+	server.handleHub(hub)
+}
+
+func (server *AppServer) handleHub(hub *RouterHub) {
 	server.Handle(hub.BasePattern, hub, false)
+	for _, pattern := range hub.extraPatterns {
+		server.Handle(pattern, hub, false)
+	}
+}
+
+// AddRouter register a handler serves any HTTP method
+func (server *AppServer) AddRouter(pattern string, handlerFunc RequestHandlerFunc, config *RouterConfig) *Router {
+	return server.AddMethodRouter("", pattern, handlerFunc, config)
 }
 
-func (server *AppServer) AddRouter(pattern string, handlerFunc RequestHandlerFunc, config *RouterConfig) {
-	router := NewRouter(pattern, handlerFunc, config)
+// AddMethodRouter register a handler serves the given HTTP method
+// Requests with other methods on the same path get 405 Method Not Allowed.
+// The router is returned, so middlewares and filters can be added to it with Router.Use and Router.AddFilter.
+func (server *AppServer) AddMethodRouter(method string, pattern string, handlerFunc RequestHandlerFunc, config *RouterConfig) *Router {
+	return server.addRouter(NewMethodRouter(method, pattern, handlerFunc, config))
+}
 
+func (server *AppServer) addRouter(router *Router) *Router {
+	server.routers = append(server.routers, router)
 	list, found := server.basePatternRouterMap[router.PathConfig.PatternString()]
 	if !found {
 		list = make([]*Router, 0)
 	}
 	list = append(list, router)
 	server.basePatternRouterMap[router.PathConfig.PatternString()] = list
+	return router
+}
+
+// GET register a handler for GET requests, HEAD requests are also served if no HEAD handler registered
+func (server *AppServer) GET(pattern string, handlerFunc RequestHandlerFunc) *Router {
+	return server.AddMethodRouter(HttpGet, pattern, handlerFunc, nil)
+}
+
+// POST register a handler for POST requests
+func (server *AppServer) POST(pattern string, handlerFunc RequestHandlerFunc) *Router {
+	return server.AddMethodRouter(HttpPost, pattern, handlerFunc, nil)
+}
+
+// PUT register a handler for PUT requests
+func (server *AppServer) PUT(pattern string, handlerFunc RequestHandlerFunc) *Router {
+	return server.AddMethodRouter(HttpPut, pattern, handlerFunc, nil)
+}
+
+// PATCH register a handler for PATCH requests
+func (server *AppServer) PATCH(pattern string, handlerFunc RequestHandlerFunc) *Router {
+	return server.AddMethodRouter(HttpPatch, pattern, handlerFunc, nil)
+}
+
+// DELETE register a handler for DELETE requests
+func (server *AppServer) DELETE(pattern string, handlerFunc RequestHandlerFunc) *Router {
+	return server.AddMethodRouter(HttpDelete, pattern, handlerFunc, nil)
+}
+
+// OPTIONS register a handler for OPTIONS requests
+func (server *AppServer) OPTIONS(pattern string, handlerFunc RequestHandlerFunc) *Router {
+	return server.AddMethodRouter(HttpOptions, pattern, handlerFunc, nil)
+}
+
+// HEAD register a handler for HEAD requests
+func (server *AppServer) HEAD(pattern string, handlerFunc RequestHandlerFunc) *Router {
+	return server.AddMethodRouter(HttpHead, pattern, handlerFunc, nil)
 }
 
 func (server *AppServer) Handle(pattern string, h RequestHandler, disableAccessLog bool) {
@@ -90,11 +206,17 @@ func (server *AppServer) Handle(pattern string, h RequestHandler, disableAccessL
 	})
 }
 
-// Start start the AppServer
-func (server *AppServer) Start() {
-	// process routers
+// OnShutdown register a hook which runs after all active requests finished during Shutdown
+func (server *AppServer) OnShutdown(hook func()) {
+	server.hooksMutex.Lock()
+	defer server.hooksMutex.Unlock()
+	server.shutdownHooks = append(server.shutdownHooks, hook)
+}
+
+// prepare register collected routers to the ServeMux
+func (server *AppServer) prepare() {
 	for basePattern, list := range server.basePatternRouterMap {
-		if len(list) == 1 && list[0].PathConfig.IsPlainPath() {
+		if len(list) == 1 && list[0].PathConfig.IsPlainPath() && list[0].Method == "" {
 			server.Handle(basePattern, list[0], list[0].Config.DisableAccessLog) // TODO: fix ugly access
 		} else {
 			// need a hub
@@ -102,9 +224,98 @@ func (server *AppServer) Start() {
 			for _, router := range list {
 				hub.AddRouter(router)
 			}
-			server.AddHub(hub)
+			server.handleHub(hub)
+		}
+	}
+	server.basePatternRouterMap = make(map[string]([]*Router), 0)
+}
+
+// Start start the AppServer and block until it stops.
+// A nil error is returned if the server is stopped by Shutdown.
+func (server *AppServer) Start() error {
+	server.prepare()
+
+	addr := server.Server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server.listenerMutex.Lock()
+	server.listener = ln
+	server.listenerMutex.Unlock()
+
+	err = server.Server.Serve(ln)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Addr the address the server listens to, nil if not started
+// It gives the port chosen by the system if the server is configured to listen to port 0.
+func (server *AppServer) Addr() net.Addr {
+	server.listenerMutex.Lock()
+	defer server.listenerMutex.Unlock()
+	if server.listener == nil {
+		return nil
+	}
+	return server.listener.Addr()
+}
+
+// Shutdown stop accepting new connections, wait for active requests to finish and run shutdown hooks.
+// If ctx expires before all requests finished, the context error is returned and hooks are still executed.
+func (server *AppServer) Shutdown(ctx context.Context) error {
+	err := server.Server.Shutdown(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		server.activeRequests.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
 		}
 	}
 
-	log.Fatal(server.Server.ListenAndServe())
+	server.hooksMutex.Lock()
+	hooks := server.shutdownHooks
+	server.shutdownHooks = nil
+	server.hooksMutex.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+	return err
+}
+
+// Run start the AppServer and shut it down gracefully when ctx is done.
+// ShutdownTimeout limits how long active requests are waited, zero means wait forever.
+func (server *AppServer) Run(ctx context.Context) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Start()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx := context.Background()
+	if server.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, server.ShutdownTimeout)
+		defer cancel()
+	}
+	err := server.Shutdown(shutdownCtx)
+	if startErr := <-errCh; startErr != nil && err == nil {
+		err = startErr
+	}
+	return err
 }
@@ -1,17 +1,58 @@
 package goweb
 
 import (
+	"context"
+	"io/ioutil"
 	"log"
-	"strconv"
+	"net/http"
+	"os"
 	"testing"
 	"time"
 )
 
-func TestMain(m *testing.M) {
-	port := 8081
+// testServerURL URL of the server started by TestMain
+var testServerURL string
+
+func testGet(path string) (int, string) {
+	res, err := http.Get(testServerURL + path)
+	if err != nil {
+		panic(err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		panic(err)
+	}
+	return res.StatusCode, string(body)
+}
+
+func testDo(method string, path string) *http.Response {
+	req, err := http.NewRequest(method, testServerURL+path, nil)
+	if err != nil {
+		panic(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	res.Body.Close()
+	return res
+}
+
+// waitServerURL wait for a server listening to port 0 to start, and get its URL
+func waitServerURL(server *AppServer) string {
+	for i := 0; i < 100; i++ {
+		if addr := server.Addr(); addr != nil {
+			return "http://" + addr.String()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	panic("server not started")
+}
 
+func TestMain(m *testing.M) {
 	appConfig := &AppServerConfig{
-		Addr:           ":" + strconv.Itoa(port),
+		Addr:           "127.0.0.1:0",
 		ReadTimeout:    1 * time.Second,
 		WriteTimeout:   5 * time.Second,
 		MaxHeaderBytes: 1 << 20,
@@ -43,6 +84,20 @@ func TestMain(m *testing.M) {
 		return nil
 	}, nil)
 
+	server.AddRouter("/archive/:year<int>?", func(req *Request, resp *Response, context *RequestContext) error {
+		resp.WriteString("archive" + req.PathParam("year"))
+		return nil
+	}, nil)
+
+	server.GET("/items/:id", func(req *Request, resp *Response, context *RequestContext) error {
+		resp.WriteString("item" + req.PathParam("id"))
+		return nil
+	})
+	server.DELETE("/items/:id", func(req *Request, resp *Response, context *RequestContext) error {
+		resp.WriteHeader(204)
+		return nil
+	})
+
 	hub := NewRouterHub("/hubs/")
 	hub.AddRequestFilter(NewRequestFilter(func(resp *Response, ctx *RequestContext) error {
 		return nil
@@ -52,6 +107,92 @@ func TestMain(m *testing.M) {
 	}, DefaultRouterConfig))
 	server.AddHub(hub)
 
-	server.Start()
-	// m.Run()
+	go func() {
+		if err := server.Start(); err != nil {
+			panic(err)
+		}
+	}()
+	testServerURL = waitServerURL(server)
+
+	code := m.Run()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Println(err)
+	}
+	os.Exit(code)
+}
+
+func TestServerRoutes(t *testing.T) {
+	code, body := testGet("/ping")
+	assert(code == 200 && body == "pong", "ping wrong")
+
+	code, body = testGet("/users/12")
+	assert(code == 200 && body == "12", "path param wrong")
+
+	code, body = testGet("/users/12/sites/34")
+	assert(code == 200 && body == "1234", "path params wrong")
+
+	code, body = testGet("/archive")
+	assert(code == 200 && body == "archive", "optional param absent wrong")
+
+	code, body = testGet("/archive/2019")
+	assert(code == 200 && body == "archive2019", "optional param present wrong")
+
+	code, _ = testGet("/archive/latest")
+	assert(code == 404, "constraint mismatch should be 404")
+}
+
+func TestServerMethods(t *testing.T) {
+	code, body := testGet("/items/1")
+	assert(code == 200 && body == "item1", "GET route wrong")
+
+	res := testDo(HttpHead, "/items/1")
+	assert(res.StatusCode == 200, "HEAD should be served by GET route")
+
+	res = testDo(HttpDelete, "/items/1")
+	assert(res.StatusCode == 204, "DELETE route wrong")
+
+	res = testDo(HttpOptions, "/items/1")
+	assert(res.StatusCode == 405 && res.Header.Get("Allow") == "DELETE, GET, HEAD", "405 response wrong")
+}
+
+func TestServerShutdown(t *testing.T) {
+	server := NewAppServer(&AppServerConfig{Addr: "127.0.0.1:0"})
+	started := make(chan struct{})
+	server.AddRouter("/slow", func(req *Request, resp *Response, ctx *RequestContext) error {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		return resp.WriteString("done")
+	}, DefaultRouterConfig)
+
+	hookCalled := false
+	server.OnShutdown(func() {
+		hookCalled = true
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- server.Run(ctx)
+	}()
+
+	serverURL := waitServerURL(server)
+	resCh := make(chan string, 1)
+	go func() {
+		res, err := http.Get(serverURL + "/slow")
+		if err != nil {
+			resCh <- ""
+			return
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		resCh <- string(body)
+	}()
+
+	<-started
+	cancel()
+	assert(<-runErr == nil, "run should stop without error")
+	assert(<-resCh == "done", "active request should be drained")
+	assert(hookCalled, "shutdown hook not called")
 }
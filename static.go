@@ -0,0 +1,287 @@
+package goweb
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// name of the catch-all param of static routers
+const staticPathParam = "filepath"
+
+// CacheRule Cache-Control header of files matching Pattern
+// Pattern is matched by path.Match against the file path relative to the root, and against the file name, eg: *.js, assets/*
+type CacheRule struct {
+	Pattern      string
+	CacheControl string
+}
+
+// StaticConfig config for serving static files
+type StaticConfig struct {
+	Index         string      // file served for directories, index.html by default
+	Browse        bool        // list files of directories without index file, directories are not found if false
+	SPAFallback   string      // file served for paths not found, eg: index.html for single page apps, 404 if empty
+	Precompressed bool        // serve name.br or name.gz instead of name if it exists and the client accepts the encoding
+	CacheControl  string      // Cache-Control header of files matching none of CacheRules
+	CacheRules    []CacheRule // the first matching rule is applied
+}
+
+// staticHandler serve files of a fs.FS
+// ETag and Last-Modified are set by modification time and size, files without modification time (embed.FS) get an ETag by content.
+// Conditional and Range requests are handled by http.ServeContent.
+type staticHandler struct {
+	fsys   fs.FS
+	config *StaticConfig
+	etags  sync.Map // file name => ETag computed by content
+}
+
+func newStaticHandler(fsys fs.FS, config *StaticConfig) *staticHandler {
+	c := StaticConfig{}
+	if config != nil {
+		c = *config
+	}
+	if c.Index == "" {
+		c.Index = "index.html"
+	}
+	return &staticHandler{fsys: fsys, config: &c}
+}
+
+// staticPattern router pattern serving files under prefix
+func staticPattern(prefix string) string {
+	return strings.TrimSuffix(prefix, "/") + "/*" + staticPathParam
+}
+
+// HandleRequest serve the file named by the catch-all param
+func (h *staticHandler) HandleRequest(req *Request, resp *Response, ctx *RequestContext) error {
+	name := strings.TrimPrefix(path.Clean("/"+req.PathParam(staticPathParam)), "/")
+	if name == "" {
+		name = "."
+	}
+
+	found, err := h.serve(req, resp, name)
+	if found || err != nil {
+		return err
+	}
+
+	if h.config.SPAFallback != "" {
+		info, err := fs.Stat(h.fsys, h.config.SPAFallback)
+		if err == nil && !info.IsDir() {
+			return h.serveFile(req, resp, h.config.SPAFallback, info)
+		}
+	}
+	resp.NotFound()
+	return nil
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid)
+}
+
+// serve serve a file or directory, found is false if there is nothing to serve
+func (h *staticHandler) serve(req *Request, resp *Response, name string) (bool, error) {
+	info, err := fs.Stat(h.fsys, name)
+	if isNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !info.IsDir() {
+		return true, h.serveFile(req, resp, name, info)
+	}
+
+	// relative links in index files and listings need a trailing slash
+	if urlPath := req.URL.Path; !strings.HasSuffix(urlPath, "/") {
+		target := urlPath + "/"
+		if req.URL.RawQuery != "" {
+			target += "?" + req.URL.RawQuery
+		}
+		resp.Redirect(target, http.StatusMovedPermanently)
+		return true, nil
+	}
+
+	index := path.Join(name, h.config.Index)
+	if indexInfo, err := fs.Stat(h.fsys, index); err == nil && !indexInfo.IsDir() {
+		return true, h.serveFile(req, resp, index, indexInfo)
+	}
+	if !h.config.Browse {
+		return false, nil
+	}
+	return true, h.list(resp, name)
+}
+
+// cacheControl Cache-Control header of a file
+func (h *staticHandler) cacheControl(name string) string {
+	for _, rule := range h.config.CacheRules {
+		if matched, _ := path.Match(rule.Pattern, name); matched {
+			return rule.CacheControl
+		}
+		if matched, _ := path.Match(rule.Pattern, path.Base(name)); matched {
+			return rule.CacheControl
+		}
+	}
+	return h.config.CacheControl
+}
+
+// acceptsEncoding test if the Accept-Encoding header accepts encoding
+func acceptsEncoding(acceptEncoding string, encoding string) bool {
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(item, ";")
+		if strings.TrimSpace(params[0]) != encoding {
+			continue
+		}
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); strings.HasPrefix(param, "q=") && err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// precompressedVariants file extensions of precompressed variants, in the order of preference
+var precompressedVariants = []struct {
+	encoding string
+	ext      string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+func (h *staticHandler) serveFile(req *Request, resp *Response, name string, info fs.FileInfo) error {
+	if cacheControl := h.cacheControl(name); cacheControl != "" {
+		resp.Header().Set("Cache-Control", cacheControl)
+	}
+
+	servedName, servedInfo := name, info
+	if h.config.Precompressed {
+		addVary(resp.Header(), "Accept-Encoding")
+		acceptEncoding := req.Header("Accept-Encoding")
+		for _, variant := range precompressedVariants {
+			if !acceptsEncoding(acceptEncoding, variant.encoding) {
+				continue
+			}
+			if variantInfo, err := fs.Stat(h.fsys, name+variant.ext); err == nil && !variantInfo.IsDir() {
+				servedName, servedInfo = name+variant.ext, variantInfo
+				resp.Header().Set("Content-Encoding", variant.encoding)
+				// content type is detected by the original name, not the compressed content
+				contentType := mime.TypeByExtension(path.Ext(name))
+				if contentType == "" {
+					contentType = "application/octet-stream"
+				}
+				resp.Header().Set("Content-Type", contentType)
+				break
+			}
+		}
+	}
+
+	f, err := h.fsys.Open(servedName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		content = bytes.NewReader(data)
+	}
+
+	etag, err := h.etag(servedName, servedInfo, content)
+	if err != nil {
+		return err
+	}
+	resp.Header().Set("ETag", etag)
+
+	http.ServeContent(resp, req.Req, path.Base(name), servedInfo.ModTime(), content)
+	return nil
+}
+
+// etag ETag of a file, by modification time and size, or by content if modification time is unknown
+func (h *staticHandler) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !info.ModTime().IsZero() {
+		return `"` + strconv.FormatInt(info.ModTime().UnixNano(), 36) + "-" + strconv.FormatInt(info.Size(), 36) + `"`, nil
+	}
+	if etag, found := h.etags.Load(name); found {
+		return etag.(string), nil
+	}
+
+	hash := fnv.New64a()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + strconv.FormatUint(hash.Sum64(), 36) + "-" + strconv.FormatInt(info.Size(), 36) + `"`
+	h.etags.Store(name, etag)
+	return etag, nil
+}
+
+// list write a HTML listing of a directory
+func (h *staticHandler) list(resp *Response, name string) error {
+	entries, err := fs.ReadDir(h.fsys, name)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString("<!doctype html>\n<pre>\n")
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		link := url.URL{Path: entryName}
+		fmt.Fprintf(&b, "<a href=\"%s\">%s</a>\n", template.HTMLEscapeString(link.String()), template.HTMLEscapeString(entryName))
+	}
+	b.WriteString("</pre>\n")
+
+	resp.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return resp.WriteString(b.String())
+}
+
+// newStaticRouter create a GET router serving files of fsys under prefix
+func newStaticRouter(prefix string, fsys fs.FS, config *StaticConfig) *Router {
+	router := NewMethodRouter(HttpGet, staticPattern(prefix), newStaticHandler(fsys, config).HandleRequest, nil)
+	router.Name = "Static"
+	return router
+}
+
+// Static serve files of a directory under the path prefix, see StaticConfig
+func (server *AppServer) Static(prefix string, dir string, config *StaticConfig) {
+	server.StaticFS(prefix, os.DirFS(dir), config)
+}
+
+// StaticFS serve files of fsys under the path prefix, see StaticConfig
+func (server *AppServer) StaticFS(prefix string, fsys fs.FS, config *StaticConfig) {
+	server.addRouter(newStaticRouter(prefix, fsys, config))
+}
+
+// Static serve files of a directory under the path prefix, see StaticConfig
+func (rh *RouterHub) Static(prefix string, dir string, config *StaticConfig) {
+	rh.StaticFS(prefix, os.DirFS(dir), config)
+}
+
+// StaticFS serve files of fsys under the path prefix, see StaticConfig
+func (rh *RouterHub) StaticFS(prefix string, fsys fs.FS, config *StaticConfig) {
+	rh.AddRouter(newStaticRouter(prefix, fsys, config))
+}
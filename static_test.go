@@ -0,0 +1,98 @@
+package goweb
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func testStaticServer() *AppServer {
+	modTime := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"index.html":     {Data: []byte("<app></app>")},
+		"js/app.js":      {Data: []byte("console.log('hello world')"), ModTime: modTime},
+		"js/app.js.gz":   {Data: []byte("gzipped"), ModTime: modTime},
+		"docs/a.txt":     {Data: []byte("a"), ModTime: modTime},
+		"docs/b c.txt":   {Data: []byte("b"), ModTime: modTime},
+		"docs/sub/c.txt": {Data: []byte("c"), ModTime: modTime},
+	}
+
+	server := NewAppServer(&AppServerConfig{})
+	server.StaticFS("/app", fsys, &StaticConfig{
+		SPAFallback:   "index.html",
+		Precompressed: true,
+		CacheControl:  "no-cache",
+		CacheRules:    []CacheRule{{Pattern: "*.js", CacheControl: "max-age=3600"}},
+	})
+	hub := NewRouterHub("/files/")
+	hub.StaticFS("/files", fsys, &StaticConfig{Browse: true})
+	server.AddHub(hub)
+	server.prepare()
+	return server
+}
+
+func testStatic(server *AppServer, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(HttpGet, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, req)
+	return w
+}
+
+func TestStaticFiles(t *testing.T) {
+	server := testStaticServer()
+
+	w := testStatic(server, "/app/js/app.js", nil)
+	assert(w.Code == 200 && w.Body.String() == "console.log('hello world')", "static file wrong")
+	assert(w.Header().Get("Cache-Control") == "max-age=3600" && w.Header().Get("Last-Modified") != "", "cache headers wrong")
+	etag := w.Header().Get("ETag")
+	assert(etag != "" && strings.Contains(w.Header().Get("Content-Type"), "javascript"), "ETag or content type wrong")
+
+	w = testStatic(server, "/app/js/app.js", map[string]string{"If-None-Match": etag})
+	assert(w.Code == 304, "conditional request should get 304")
+
+	w = testStatic(server, "/app/js/app.js", map[string]string{"Range": "bytes=0-6"})
+	assert(w.Code == 206 && w.Body.String() == "console", "range request wrong")
+
+	w = testStatic(server, "/app/js/app.js", map[string]string{"Accept-Encoding": "br, gzip"})
+	assert(w.Body.String() == "gzipped" && w.Header().Get("Content-Encoding") == "gzip", "precompressed variant not served")
+	assert(strings.Contains(w.Header().Get("Content-Type"), "javascript") && w.Header().Get("Vary") == "Accept-Encoding", "precompressed headers wrong")
+	w = testStatic(server, "/app/js/app.js", map[string]string{"Accept-Encoding": "gzip;q=0"})
+	assert(w.Body.String() != "gzipped", "refused encoding should not be served")
+
+	w = testStatic(server, "/app/", nil)
+	assert(w.Code == 200 && w.Body.String() == "<app></app>" && w.Header().Get("Cache-Control") == "no-cache", "index wrong")
+	assert(w.Header().Get("ETag") != "", "ETag by content wrong")
+	w = testStatic(server, "/app/users/12", nil)
+	assert(w.Code == 200 && w.Body.String() == "<app></app>", "SPA fallback wrong")
+
+	h := newStaticHandler(fstest.MapFS{"a.txt": {Data: []byte("a")}}, nil)
+	w = httptest.NewRecorder()
+	req := NewRequest(httptest.NewRequest(HttpGet, "/x", nil))
+	req.pathParam = map[string]string{staticPathParam: "../../a.txt"}
+	err := h.HandleRequest(req, &Response{Writer: w, Context: NewRequestContext(req)}, nil)
+	assert(err == nil && w.Body.String() == "a", "path should be cleaned within the root")
+}
+
+func TestStaticBrowse(t *testing.T) {
+	server := testStaticServer()
+
+	w := testStatic(server, "/files/docs/", nil)
+	body := w.Body.String()
+	assert(w.Code == 200 && strings.Contains(body, `<a href="a.txt">a.txt</a>`), "listing wrong")
+	assert(strings.Contains(body, `<a href="b%20c.txt">b c.txt</a>`) && strings.Contains(body, `<a href="sub/">sub/</a>`), "listing links wrong")
+
+	w = testStatic(server, "/files/docs", nil)
+	assert(w.Code == 301 && w.Header().Get("Location") == "/files/docs/", "directory should be redirected to trailing slash")
+
+	w = testStatic(server, "/files/docs/missing.txt", nil)
+	assert(w.Code == 404, "missing file should be 404")
+
+	w = httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, httptest.NewRequest(HttpHead, "/files/docs/a.txt", nil))
+	assert(w.Code == 200 && w.Body.Len() == 0, "HEAD request wrong")
+}
@@ -1,73 +1,160 @@
 package goweb
 
 import (
+	"encoding"
 	"errors"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+// StringCaster cast a string to a value of the type it is registered for
+type StringCaster func(src string) (interface{}, error)
+
+var (
+	stringCasters      = make(map[reflect.Type]StringCaster, 0)
+	stringCastersMutex sync.RWMutex
+
+	typeTime            = reflect.TypeOf(time.Time{})
+	typeDuration        = reflect.TypeOf(time.Duration(0))
+	typeTextUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// RegisterStringCaster register a caster for values of type t, registered casters take precedence over the built-in ones
+// The caster must return a value of type t.
+// Casters must be registered at init time, before forms using type t are inspected:
+// form meta is cached on first use, and a struct inspected without a caster stays a nested form.
+func RegisterStringCaster(t reflect.Type, caster StringCaster) {
+	stringCastersMutex.Lock()
+	defer stringCastersMutex.Unlock()
+	stringCasters[t] = caster
+}
+
+func lookupStringCaster(t reflect.Type) StringCaster {
+	stringCastersMutex.RLock()
+	defer stringCastersMutex.RUnlock()
+	return stringCasters[t]
+}
+
+// hasStringCaster test if type t is cast from a string as a whole, rather than filled as a nested form
+func hasStringCaster(t reflect.Type) bool {
+	return t == typeTime || lookupStringCaster(t) != nil || reflect.PtrTo(t).Implements(typeTextUnmarshaler)
+}
+
+// parseBool parse a bool value, true/false, 1/0, t/f and on/off (checkboxes) are accepted
+func parseBool(src string) (bool, error) {
+	switch strings.ToLower(src) {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return strconv.ParseBool(src)
+}
+
 // StringCast cast string type value to dest type
-// Supported dest types: bool, int(int8 ~ int64), uint(uint8 ~ uint64), string, []bool, []int(int8 ~ int64), []uint(uint8 ~ uint64), []string;
+// Supported dest types: bool, int(int8 ~ int64), uint(uint8 ~ uint64), float32, float64, string, time.Time (RFC 3339),
+// time.Duration, types implementing encoding.TextUnmarshaler, types with a registered StringCaster,
+// pointers to and slices of them. Slices are decoded from comma separated values.
+// An empty string is cast to the zero value.
 func StringCast(src string, typo *reflect.Type) (interface{}, error) {
-	var v interface{}
-	var err error
+	v, err := castString(src, *typo, "")
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
 
-	switch (*typo).Kind() {
-	case reflect.String:
-		v = src
-	case reflect.Bool:
-		v = src == "true"
-	case reflect.Int:
-		v, err = strconv.Atoi(src)
-	case reflect.Int8:
-		v, err = strconv.ParseInt(src, 10, 8)
-	case reflect.Int16:
-		v, err = strconv.ParseInt(src, 10, 16)
-	case reflect.Int32:
-		v, err = strconv.ParseInt(src, 10, 32)
-	case reflect.Int64:
-		v, err = strconv.ParseInt(src, 10, 64)
-	case reflect.Uint:
-		var vOfU64 uint64
-		if vOfU64, err = strconv.ParseUint(src, 10, 0); err == nil {
-			v = uint(vOfU64)
+// castString cast src to a value of type typo, layout is used to parse time.Time values
+func castString(src string, typo reflect.Type, layout string) (reflect.Value, error) {
+	v := reflect.New(typo).Elem()
+	if src == "" {
+		return v, nil
+	}
+
+	if caster := lookupStringCaster(typo); caster != nil {
+		value, err := caster(src)
+		if err != nil {
+			return v, err
 		}
-	case reflect.Uint8:
-		v, err = strconv.ParseUint(src, 10, 8)
-	case reflect.Uint16:
-		v, err = strconv.ParseUint(src, 10, 16)
-	case reflect.Uint32:
-		v, err = strconv.ParseUint(src, 10, 32)
-	case reflect.Uint64:
-		v, err = strconv.ParseUint(src, 10, 64)
-	case reflect.Slice:
-		list := reflect.MakeSlice(*typo, 0, 0)
-		if src == "" {
-			return list.Interface(), err
+		vv := reflect.ValueOf(value)
+		if !vv.IsValid() || !vv.Type().AssignableTo(typo) {
+			return v, errors.New("StringCaster of " + typo.String() + " returned a value of wrong type")
 		}
+		v.Set(vv)
+		return v, nil
+	}
 
-		parts := strings.Split(src, ",")
-		elementType := (*typo).Elem()
-		if elementType.Kind() == reflect.String {
-			return parts, nil
+	switch {
+	case typo == typeTime:
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, src)
+		if err != nil {
+			return v, err
+		}
+		v.Set(reflect.ValueOf(t))
+		return v, nil
+	case typo == typeDuration:
+		d, err := time.ParseDuration(src)
+		if err != nil {
+			return v, err
 		}
+		v.SetInt(int64(d))
+		return v, nil
+	case reflect.PtrTo(typo).Implements(typeTextUnmarshaler):
+		err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(src))
+		return v, err
+	}
 
+	var err error
+	switch typo.Kind() {
+	case reflect.String:
+		v.SetString(src)
+	case reflect.Bool:
+		var b bool
+		if b, err = parseBool(src); err == nil {
+			v.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		if n, err = strconv.ParseInt(src, 10, typo.Bits()); err == nil {
+			v.SetInt(n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		if n, err = strconv.ParseUint(src, 10, typo.Bits()); err == nil {
+			v.SetUint(n)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(src, typo.Bits()); err == nil {
+			v.SetFloat(f)
+		}
+	case reflect.Ptr:
+		var elem reflect.Value
+		if elem, err = castString(src, typo.Elem(), layout); err == nil {
+			ptr := reflect.New(typo.Elem())
+			ptr.Elem().Set(elem)
+			v.Set(ptr)
+		}
+	case reflect.Slice:
+		parts := strings.Split(src, ",")
+		list := reflect.MakeSlice(typo, 0, len(parts))
 		for _, itemV := range parts {
-			vCtv, err := StringCast(itemV, &elementType)
+			item, err := castString(itemV, typo.Elem(), layout)
 			if err != nil {
-				return nil, err
+				return v, err
 			}
-			list = reflect.AppendSlice(list, reflect.ValueOf(vCtv))
+			list = reflect.Append(list, item)
 		}
-		v = list.Interface()
+		v.Set(list)
 	default:
-		return nil, errors.New("Unsupported type cast: " + (*typo).Kind().String())
-	}
-
-	if err != nil {
-		return nil, err
+		return v, errors.New("Unsupported type cast: " + typo.String())
 	}
 
-	return v, nil
+	return v, err
 }
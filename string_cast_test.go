@@ -1,9 +1,12 @@
 package goweb
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func TestCastUint(t *testing.T) {
@@ -15,3 +18,112 @@ func TestCastUint(t *testing.T) {
 	}
 	fmt.Println(reflect.ValueOf(v).Type().Kind() == reflect.Uint)
 }
+
+type testLevel int
+
+type testHexID uint32
+
+func (id *testHexID) UnmarshalText(text []byte) error {
+	n, err := strconv.ParseUint(string(text), 16, 32)
+	*id = testHexID(n)
+	return err
+}
+
+func TestStringCastTypes(t *testing.T) {
+	cast := func(src string, v interface{}) (interface{}, error) {
+		typo := reflect.TypeOf(v)
+		return StringCast(src, &typo)
+	}
+
+	v, err := cast("-8", int8(0))
+	assert(err == nil && v.(int8) == -8, "int8 wrong")
+	_, err = cast("300", uint8(0))
+	assert(err != nil, "uint8 overflow should be rejected")
+	v, err = cast("1.5", float32(0))
+	assert(err == nil && v.(float32) == 1.5, "float32 wrong")
+	v, err = cast("1,2,3", []int16{})
+	assert(err == nil && reflect.DeepEqual(v, []int16{1, 2, 3}), "[]int16 wrong")
+	v, err = cast("1m30s", time.Duration(0))
+	assert(err == nil && v.(time.Duration) == 90*time.Second, "duration wrong")
+	v, err = cast("2024-05-01T08:00:00Z", time.Time{})
+	assert(err == nil && v.(time.Time).Month() == time.May, "time wrong")
+	v, err = cast("ff", testHexID(0))
+	assert(err == nil && v.(testHexID) == 255, "TextUnmarshaler wrong")
+	v, err = cast("7", new(int))
+	assert(err == nil && *v.(*int) == 7, "pointer wrong")
+
+	for _, src := range []string{"true", "1", "on", "T"} {
+		v, err = cast(src, false)
+		assert(err == nil && v.(bool), "bool true wrong: "+src)
+	}
+	_, err = cast("yes please", false)
+	assert(err != nil, "garbage bool should be rejected")
+
+	registerTestStringCaster(t, reflect.TypeOf(testLevel(0)), func(src string) (interface{}, error) {
+		levels := map[string]testLevel{"debug": 0, "info": 1, "error": 2}
+		if level, found := levels[src]; found {
+			return level, nil
+		}
+		return nil, errors.New("unknown level " + src)
+	})
+	v, err = cast("error", testLevel(0))
+	assert(err == nil && v.(testLevel) == 2, "registered caster wrong")
+	_, err = cast("fatal", testLevel(0))
+	assert(err != nil, "registered caster error should be returned")
+}
+
+func TestFillFormTimeLayout(t *testing.T) {
+	type eventForm struct {
+		Day     time.Time     `form:"day,layout=2006-01-02"`
+		At      *time.Time    `form:"at"`
+		Timeout time.Duration `form:"timeout,default=5s"`
+		Notify  bool          `form:"notify"`
+	}
+	form := &eventForm{}
+	err := testQueryRequest("day=2024-05-01&notify=on").FillForm(form)
+	assert(err == nil && form.Day.Day() == 1 && form.At == nil && form.Timeout == 5*time.Second && form.Notify, "time fields wrong")
+
+	err = testQueryRequest("day=01/05/2024&notify=maybe").FillForm(form)
+	var fes FormErrors
+	assert(errors.As(err, &fes) && len(fes) == 2 && fes[0].Type == FormErrTypeCannotCast, "invalid values should be rejected")
+}
+
+type testPoint struct {
+	X int `form:"x"`
+	Y int `form:"y"`
+}
+
+// registerTestStringCaster register a caster for a test, it is removed together with cached forms when the test finishes
+func registerTestStringCaster(t *testing.T, typo reflect.Type, caster StringCaster) {
+	RegisterStringCaster(typo, caster)
+	t.Cleanup(func() {
+		stringCastersMutex.Lock()
+		delete(stringCasters, typo)
+		stringCastersMutex.Unlock()
+		formCache.Range(func(key, value interface{}) bool {
+			formCache.Delete(key)
+			return true
+		})
+	})
+}
+
+func TestRegisterStringCasterNestedForm(t *testing.T) {
+	type shapeForm struct {
+		Origin testPoint `form:"origin"`
+	}
+	type pointForm struct {
+		Origin testPoint `form:"origin"`
+	}
+	form := &shapeForm{}
+	err := testQueryRequest("origin.x=1&origin.y=2").FillForm(form)
+	assert(err == nil && form.Origin.X == 1 && form.Origin.Y == 2, "struct without caster should be a nested form")
+
+	registerTestStringCaster(t, reflect.TypeOf(testPoint{}), func(src string) (interface{}, error) {
+		var p testPoint
+		_, err := fmt.Sscanf(src, "%d:%d", &p.X, &p.Y)
+		return p, err
+	})
+	pf := &pointForm{}
+	err = testQueryRequest("origin=3:4").FillForm(pf)
+	assert(err == nil && pf.Origin.X == 3 && pf.Origin.Y == 4, "struct with caster should be cast as a whole")
+}
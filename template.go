@@ -0,0 +1,205 @@
+package goweb
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// TemplateConfig config for a TemplateEngine
+type TemplateConfig struct {
+	Extension   string           // extension of template files, .html by default
+	LayoutsDir  string           // directory of layouts, layouts by default
+	PartialsDir string           // directory of partials, partials by default
+	Layout      string           // name of the default layout, eg: layouts/base. Pages are rendered without layout if empty
+	FuncMap     template.FuncMap // functions shared by all templates
+	Reload      bool             // re-parse templates when files change, for development
+}
+
+// TemplateEngine parse templates once and render them by name
+// Every template file except layouts and partials is a page, named by its path without extension, eg: users/show.
+// A page is parsed together with all layouts and partials, so it can use partials with {{template "partials/nav" .}}.
+// When rendered with a layout, the layout is executed and the page defines the blocks the layout uses, eg:
+//      layouts/base.html:  <body>{{block "content" .}}{{end}}</body>
+//      users/show.html:    {{define "content"}}{{.Name}}{{end}}
+type TemplateEngine struct {
+	FS          fs.FS
+	Config      *TemplateConfig
+	pages       map[string]*template.Template
+	fingerprint string
+	mutex       sync.RWMutex
+}
+
+// NewTemplateEngine create a template engine loading templates from fsys, like os.DirFS(dir) or an embed.FS
+// Templates are parsed immediately, parse errors are returned.
+func NewTemplateEngine(fsys fs.FS, config *TemplateConfig) (*TemplateEngine, error) {
+	if config == nil {
+		config = &TemplateConfig{}
+	}
+	c := *config
+	if c.Extension == "" {
+		c.Extension = ".html"
+	}
+	if c.LayoutsDir == "" {
+		c.LayoutsDir = "layouts"
+	}
+	if c.PartialsDir == "" {
+		c.PartialsDir = "partials"
+	}
+
+	engine := &TemplateEngine{FS: fsys, Config: &c}
+	if err := engine.Load(); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+// templateFiles list template files in the FS, and a fingerprint telling if any of them changed
+func (engine *TemplateEngine) templateFiles() ([]string, string, error) {
+	files := make([]string, 0)
+	var fingerprint strings.Builder
+	err := fs.WalkDir(engine.FS, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(name) != engine.Config.Extension {
+			return nil
+		}
+		files = append(files, name)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fingerprint.WriteString(name + ":" + strconv.FormatInt(info.ModTime().UnixNano(), 10) + ":" + strconv.FormatInt(info.Size(), 10) + ";")
+		return nil
+	})
+	sort.Strings(files)
+	return files, fingerprint.String(), err
+}
+
+func (engine *TemplateEngine) isShared(name string) bool {
+	return strings.HasPrefix(name, engine.Config.LayoutsDir+"/") || strings.HasPrefix(name, engine.Config.PartialsDir+"/")
+}
+
+// Load parse all templates, templates loaded before are kept if any of them fails to parse
+func (engine *TemplateEngine) Load() error {
+	files, fingerprint, err := engine.templateFiles()
+	if err != nil {
+		return errors.WithMessage(err, "load templates")
+	}
+
+	base := template.New("").Funcs(engine.Config.FuncMap)
+	pageFiles := make([]string, 0)
+	for _, file := range files {
+		if !engine.isShared(file) {
+			pageFiles = append(pageFiles, file)
+			continue
+		}
+		if err := engine.parseFile(base, file); err != nil {
+			return err
+		}
+	}
+
+	pages := make(map[string]*template.Template, len(pageFiles))
+	for _, file := range pageFiles {
+		page, err := base.Clone()
+		if err != nil {
+			return errors.WithMessage(err, file)
+		}
+		if err := engine.parseFile(page, file); err != nil {
+			return err
+		}
+		pages[engine.templateName(file)] = page
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	engine.pages = pages
+	engine.fingerprint = fingerprint
+	return nil
+}
+
+func (engine *TemplateEngine) templateName(file string) string {
+	return strings.TrimSuffix(file, engine.Config.Extension)
+}
+
+func (engine *TemplateEngine) parseFile(t *template.Template, file string) error {
+	content, err := fs.ReadFile(engine.FS, file)
+	if err != nil {
+		return errors.WithMessage(err, "load templates")
+	}
+	if _, err := t.New(engine.templateName(file)).Parse(string(content)); err != nil {
+		return errors.WithMessage(err, file)
+	}
+	return nil
+}
+
+// reloadIfChanged reload templates if any file changed since templates were loaded
+func (engine *TemplateEngine) reloadIfChanged() error {
+	_, fingerprint, err := engine.templateFiles()
+	if err != nil {
+		return errors.WithMessage(err, "load templates")
+	}
+	engine.mutex.RLock()
+	changed := fingerprint != engine.fingerprint
+	engine.mutex.RUnlock()
+	if !changed {
+		return nil
+	}
+	return engine.Load()
+}
+
+// View get a view of the page with the default layout, which can be rendered by Response.Negotiate
+func (engine *TemplateEngine) View(name string, data interface{}) (*TemplateView, error) {
+	return engine.ViewWithLayout(name, engine.Config.Layout, data)
+}
+
+// ViewWithLayout get a view of the page with the given layout, the page is rendered without layout if layout is empty
+func (engine *TemplateEngine) ViewWithLayout(name string, layout string, data interface{}) (*TemplateView, error) {
+	if engine.Config.Reload {
+		if err := engine.reloadIfChanged(); err != nil {
+			return nil, err
+		}
+	}
+
+	engine.mutex.RLock()
+	page, found := engine.pages[name]
+	engine.mutex.RUnlock()
+	if !found {
+		return nil, errors.WithMessage(ErrTemplateNotFound, name)
+	}
+
+	view := &TemplateView{Template: page, Name: name, Data: data}
+	if layout != "" {
+		if page.Lookup(layout) == nil {
+			return nil, errors.WithMessage(ErrTemplateNotFound, layout)
+		}
+		view.Name = layout
+	}
+	return view, nil
+}
+
+// Render render the page with the default layout to w
+// The page is executed into a buffer first, so nothing is written if it fails.
+func (engine *TemplateEngine) Render(w io.Writer, name string, data interface{}) error {
+	view, err := engine.View(name, data)
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := view.render(buf); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
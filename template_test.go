@@ -0,0 +1,93 @@
+package goweb
+
+import (
+	"html/template"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func testTemplateFS() fstest.MapFS {
+	return fstest.MapFS{
+		"layouts/base.html": {Data: []byte(`<html>{{template "partials/nav" .}}{{block "content" .}}{{end}}</html>`)},
+		"partials/nav.html": {Data: []byte(`<nav>{{upper .Site}}</nav>`)},
+		"users/show.html":   {Data: []byte(`{{define "content"}}<p>{{.Name}}</p>{{end}}`)},
+		"home.html":         {Data: []byte(`{{define "content"}}home{{end}}`)},
+		"plain.html":        {Data: []byte(`plain {{template "partials/nav" .}}`)},
+		"readme.txt":        {Data: []byte(`not a template`)},
+	}
+}
+
+func TestTemplateEngine(t *testing.T) {
+	fsys := testTemplateFS()
+	engine, err := NewTemplateEngine(fsys, &TemplateConfig{
+		Layout:  "layouts/base",
+		FuncMap: template.FuncMap{"upper": strings.ToUpper},
+		Reload:  true,
+	})
+	assert(err == nil, "load templates failed")
+
+	data := map[string]string{"Site": "go", "Name": "<bob>"}
+	var b strings.Builder
+	err = engine.Render(&b, "users/show", data)
+	assert(err == nil && b.String() == "<html><nav>GO</nav><p>&lt;bob&gt;</p></html>", "render with layout wrong: "+b.String())
+
+	view, err := engine.ViewWithLayout("plain", "", data)
+	b.Reset()
+	assert(err == nil && view.render(&b) == nil && b.String() == "plain <nav>GO</nav>", "render without layout wrong")
+
+	_, err = engine.View("missing", data)
+	assert(errors.Cause(err) == ErrTemplateNotFound, "missing template should be an error")
+
+	fsys["home.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}new home{{end}}`), ModTime: time.Now()}
+	b.Reset()
+	err = engine.Render(&b, "home", data)
+	assert(err == nil && strings.Contains(b.String(), "new home"), "changed template should be reloaded")
+
+	fsys["home.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}{{.Name}{{end}}`), ModTime: time.Now().Add(time.Second)}
+	b.Reset()
+	err = engine.Render(&b, "users/show", data)
+	assert(err != nil && strings.Contains(err.Error(), "home.html"), "reload error should be returned")
+
+	_, err = NewTemplateEngine(fstest.MapFS{"bad.html": {Data: []byte(`{{if}}`)}}, nil)
+	assert(err != nil, "parse error should be returned")
+}
+
+func TestResponseRender(t *testing.T) {
+	engine, err := NewTemplateEngine(testTemplateFS(), &TemplateConfig{
+		Layout:  "layouts/base",
+		FuncMap: template.FuncMap{"upper": strings.ToUpper},
+	})
+	assert(err == nil, "load templates failed")
+
+	w := httptest.NewRecorder()
+	req := NewRequest(httptest.NewRequest(HttpGet, "/", nil))
+	resp := &Response{Writer: w, Context: NewRequestContext(req), templates: engine}
+	err = resp.Render(201, "users/show", map[string]string{"Site": "go", "Name": "bob"})
+	assert(err == nil && w.Code == 201 && w.Body.String() == "<html><nav>GO</nav><p>bob</p></html>", "render wrong")
+	assert(w.Header().Get("Content-Type") == "text/html; charset=utf-8", "render content type wrong")
+
+	w = httptest.NewRecorder()
+	resp = &Response{Writer: w, Context: NewRequestContext(req), templates: engine}
+	err = resp.Render(200, "users/show", nil)
+	assert(err != nil && !resp.Context.Finished(), "failed render should write nothing")
+
+	resp = &Response{Writer: httptest.NewRecorder(), Context: NewRequestContext(req)}
+	assert(resp.Render(200, "home", nil) == ErrNoTemplateEngine, "render without engine should fail")
+	assert(resp.RenderTemplate(nil, "no/such/file.html") != nil, "missing template file should be an error")
+
+	file := filepath.Join(t.TempDir(), "page.html")
+	for _, content := range []string{"v1", "v2"} {
+		assert(os.WriteFile(file, []byte(content), 0644) == nil, "write template file failed")
+		w = httptest.NewRecorder()
+		resp = &Response{Writer: w, Context: NewRequestContext(req)}
+		err = resp.RenderTemplate(nil, file)
+		assert(err == nil && w.Body.String() == content, "template files should not be cached: "+w.Body.String())
+	}
+}
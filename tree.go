@@ -0,0 +1,256 @@
+package goweb
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// pathParamValue a captured path param
+type pathParamValue struct {
+	Name  string
+	Value string
+}
+
+// pathParamValues captured path params in the order they appear in the url path
+type pathParamValues []pathParamValue
+
+// Map convert captured path params to a map, nil is returned if there is no param
+func (ps pathParamValues) Map() map[string]string {
+	if len(ps) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(ps))
+	for _, p := range ps {
+		m[p.Name] = p.Value
+	}
+	return m
+}
+
+// routeLookup state of a single lookup in the route tree
+type routeLookup struct {
+	method  string
+	params  pathParamValues
+	matched *routeNode // the first node matches the path regardless of the method
+}
+
+// routeMatch the result of looking up a path in the tree of a hub
+type routeMatch struct {
+	hub    *RouterHub
+	path   string
+	router *Router
+	lookup routeLookup
+}
+
+// routeNode a node of the compressed prefix tree used to match url paths.
+// Static children are indexed by their first byte and always tried first,
+// then param children in order (constrained params before unconstrained ones), then the catch-all child.
+type routeNode struct {
+	path     string             // static prefix of this node, or param name for a param node
+	param    *PathParam         // param matched by this node, nil for static nodes
+	indices  string             // first bytes of static children
+	children []*routeNode       // static children
+	params   []*routeNode       // ":name" children
+	catchAll *routeNode         // "*name" child
+	routers  map[string]*Router // routers by HTTP method, the empty method matches any method
+}
+
+// routerFor get router handles the method, HEAD requests are served by GET routers if not registered
+func (n *routeNode) routerFor(method string) *Router {
+	if router, found := n.routers[method]; found {
+		return router
+	}
+	if method == HttpHead {
+		if router, found := n.routers[HttpGet]; found {
+			return router
+		}
+	}
+	return n.routers[""]
+}
+
+// allowedMethods list HTTP methods can be served by this node
+func (n *routeNode) allowedMethods() []string {
+	methods := make([]string, 0, len(n.routers)+1)
+	for method := range n.routers {
+		methods = append(methods, method)
+	}
+	return allowedMethods(methods)
+}
+
+func longestCommonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+func (n *routeNode) staticChild(c byte) *routeNode {
+	i := strings.IndexByte(n.indices, c)
+	if i < 0 {
+		return nil
+	}
+	return n.children[i]
+}
+
+// split cut the static prefix of n at i, the rest goes to a new child
+func (n *routeNode) split(i int) {
+	tail := *n
+	tail.path = n.path[i:]
+	*n = routeNode{
+		path:     n.path[:i],
+		indices:  tail.path[:1],
+		children: []*routeNode{&tail},
+	}
+}
+
+// addStatic walk down static children of n to consume s, split or create nodes when needed
+func (n *routeNode) addStatic(s string) *routeNode {
+	for s != "" {
+		child := n.staticChild(s[0])
+		if child == nil {
+			child = &routeNode{path: s}
+			n.indices += s[:1]
+			n.children = append(n.children, child)
+			return child
+		}
+		i := longestCommonPrefix(s, child.path)
+		if i < len(child.path) {
+			child.split(i)
+		}
+		n = child
+		s = s[i:]
+	}
+	return n
+}
+
+// addParam get or create the param child of n
+// Params with the same constraint at the same position must have the same name.
+func (n *routeNode) addParam(p *PathParam) (*routeNode, error) {
+	if p.CatchAll {
+		if n.catchAll == nil {
+			n.catchAll = &routeNode{path: p.Name, param: p}
+		} else if n.catchAll.path != p.Name {
+			return nil, errors.Wrapf(ErrRouteConflict, "catch-all param *%s conflicts with *%s", p.Name, n.catchAll.path)
+		}
+		return n.catchAll, nil
+	}
+
+	for _, child := range n.params {
+		if child.param.Constraint != p.Constraint {
+			continue
+		}
+		if child.path != p.Name {
+			return nil, errors.Wrapf(ErrRouteConflict, "path param :%s conflicts with :%s", p.Name, child.path)
+		}
+		return child, nil
+	}
+
+	child := &routeNode{path: p.Name, param: p}
+	if p.Constraint == "" {
+		n.params = append(n.params, child)
+	} else {
+		// keep unconstrained param as the last one
+		i := len(n.params)
+		if i > 0 && n.params[i-1].param.Constraint == "" {
+			i--
+		}
+		n.params = append(n.params, nil)
+		copy(n.params[i+1:], n.params[i:])
+		n.params[i] = child
+	}
+	return child, nil
+}
+
+// addSegments insert a router to the tree rooted at n
+func (n *routeNode) addSegments(segments []pathSegment, router *Router) error {
+	cur := n
+	for _, seg := range segments {
+		if seg.param == nil {
+			cur = cur.addStatic(seg.static)
+			continue
+		}
+		var err error
+		if cur, err = cur.addParam(seg.param); err != nil {
+			return err
+		}
+	}
+
+	if found, exists := cur.routers[router.Method]; exists {
+		return errors.Wrapf(ErrRouteConflict, "already registered by %s", found)
+	}
+	if cur.routers == nil {
+		cur.routers = make(map[string]*Router, 1)
+	}
+	cur.routers[router.Method] = router
+	return nil
+}
+
+// addRoute insert a router to the tree rooted at n, every variant of optional params is inserted
+func (n *routeNode) addRoute(router *Router) error {
+	for _, segments := range router.PathConfig.variants() {
+		if err := n.addSegments(segments, router); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// find search the router matches the remaining path and the method of lk, captured params are appended to lk.params.
+// No memory is allocated if no param is captured.
+func (n *routeNode) find(path string, lk *routeLookup) *Router {
+	if path == "" {
+		if len(n.routers) > 0 {
+			if router := n.routerFor(lk.method); router != nil {
+				return router
+			}
+			if lk.matched == nil {
+				lk.matched = n
+			}
+		}
+		if n.catchAll != nil {
+			lk.params = append(lk.params, pathParamValue{Name: n.catchAll.path})
+			if router := n.catchAll.find("", lk); router != nil {
+				return router
+			}
+			lk.params = lk.params[:len(lk.params)-1]
+		}
+		return nil
+	}
+
+	if child := n.staticChild(path[0]); child != nil && strings.HasPrefix(path, child.path) {
+		if router := child.find(path[len(child.path):], lk); router != nil {
+			return router
+		}
+	}
+
+	if len(n.params) > 0 {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end > 0 {
+			value := path[:end]
+			for _, child := range n.params {
+				if !child.param.MatchValue(value) {
+					continue
+				}
+				lk.params = append(lk.params, pathParamValue{Name: child.path, Value: value})
+				if router := child.find(path[end:], lk); router != nil {
+					return router
+				}
+				lk.params = lk.params[:len(lk.params)-1]
+			}
+		}
+	}
+
+	if n.catchAll != nil {
+		lk.params = append(lk.params, pathParamValue{Name: n.catchAll.path, Value: path})
+		if router := n.catchAll.find("", lk); router != nil {
+			return router
+		}
+		lk.params = lk.params[:len(lk.params)-1]
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package goweb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func testTree(patterns ...string) *routeNode {
+	root := &routeNode{}
+	for _, pattern := range patterns {
+		if err := root.addRoute(NewRouter(pattern, nil, DefaultRouterConfig)); err != nil {
+			panic(err)
+		}
+	}
+	return root
+}
+
+func testFind(root *routeNode, path string) (string, map[string]string) {
+	lk := routeLookup{method: HttpGet}
+	router := root.find(path, &lk)
+	if router == nil {
+		return "", nil
+	}
+	return router.PathConfig.Path, lk.params.Map()
+}
+
+func TestTreeFind(t *testing.T) {
+	root := testTree(
+		"/users/",
+		"/users/:userId",
+		"/users/me",
+		"/users/:userId/sites/:siteId",
+		"/users/:userId/sites/new",
+		"/user",
+		"/sites/:siteId/",
+	)
+
+	pattern, params := testFind(root, "/users/")
+	assert(pattern == "/users/" && params == nil, "plain path wrong")
+
+	pattern, params = testFind(root, "/users/me")
+	assert(pattern == "/users/me" && params == nil, "static segment should take precedence")
+
+	pattern, params = testFind(root, "/users/mean")
+	assert(pattern == "/users/:userId" && params["userId"] == "mean", "param segment wrong")
+
+	pattern, params = testFind(root, "/users/12/sites/new")
+	assert(pattern == "/users/:userId/sites/new" && params["userId"] == "12", "static after param wrong")
+
+	pattern, params = testFind(root, "/users/12/sites/34")
+	assert(pattern == "/users/:userId/sites/:siteId" && params["userId"] == "12" && params["siteId"] == "34", "params wrong")
+
+	pattern, _ = testFind(root, "/user")
+	assert(pattern == "/user", "split node wrong")
+
+	pattern, params = testFind(root, "/sites/34/")
+	assert(pattern == "/sites/:siteId/" && params["siteId"] == "34", "trailing slash wrong")
+
+	for _, path := range []string{"/users", "/users/12/", "/users/12/sites/", "/sites/34", "/us", "/"} {
+		pattern, _ = testFind(root, path)
+		assert(pattern == "", "should not match "+path)
+	}
+}
+
+func TestTreeBacktrack(t *testing.T) {
+	root := testTree("/files/static/a", "/files/:name/b")
+
+	pattern, params := testFind(root, "/files/static/b")
+	assert(pattern == "/files/:name/b" && params["name"] == "static", "backtrack wrong")
+}
+
+func TestTreeConflict(t *testing.T) {
+	root := testTree("/users/:userId", "/files/*path")
+
+	err := root.addRoute(NewRouter("/users/:id", nil, DefaultRouterConfig))
+	assert(errors.Cause(err) == ErrRouteConflict, "param name conflict not reported")
+
+	err = root.addRoute(NewRouter("/users/:userId", nil, DefaultRouterConfig))
+	assert(errors.Cause(err) == ErrRouteConflict, "duplicated route not reported")
+
+	err = root.addRoute(NewRouter("/files/*name", nil, DefaultRouterConfig))
+	assert(errors.Cause(err) == ErrRouteConflict, "catch-all name conflict not reported")
+
+	err = root.addRoute(NewRouter("/users/:id<int>", nil, DefaultRouterConfig))
+	assert(err == nil, "params with different constraints should not conflict")
+}
+
+func TestTreeTypedParams(t *testing.T) {
+	root := testTree(
+		"/users/:name",
+		"/users/:id<int>",
+		"/users/:uid<uuid>/profile",
+		"/files/:file<[a-z]+\\.txt>",
+	)
+
+	pattern, params := testFind(root, "/users/42")
+	assert(pattern == "/users/:id<int>" && params["id"] == "42", "int constraint wrong")
+
+	pattern, params = testFind(root, "/users/bob")
+	assert(pattern == "/users/:name" && params["name"] == "bob", "fall through to unconstrained param wrong")
+
+	pattern, params = testFind(root, "/users/123e4567-e89b-12d3-a456-426614174000/profile")
+	assert(pattern == "/users/:uid<uuid>/profile" && params["uid"] == "123e4567-e89b-12d3-a456-426614174000", "uuid constraint wrong")
+
+	pattern, params = testFind(root, "/files/readme.txt")
+	assert(pattern == "/files/:file<[a-z]+\\.txt>" && params["file"] == "readme.txt", "regexp constraint wrong")
+
+	pattern, _ = testFind(root, "/files/readme.md")
+	assert(pattern == "", "regexp constraint should not match")
+}
+
+func TestTreeCatchAllAndOptional(t *testing.T) {
+	root := testTree("/static/*filepath", "/static/favicon.ico", "/posts/:year<int>/:month<int>?")
+
+	pattern, params := testFind(root, "/static/css/site.css")
+	assert(pattern == "/static/*filepath" && params["filepath"] == "css/site.css", "catch-all wrong")
+
+	pattern, params = testFind(root, "/static/")
+	assert(pattern == "/static/*filepath" && params["filepath"] == "", "empty catch-all wrong")
+
+	pattern, _ = testFind(root, "/static/favicon.ico")
+	assert(pattern == "/static/favicon.ico", "static should take precedence over catch-all")
+
+	pattern, params = testFind(root, "/posts/2019")
+	assert(pattern == "/posts/:year<int>/:month<int>?" && params["year"] == "2019" && params["month"] == "", "optional param absent wrong")
+
+	pattern, params = testFind(root, "/posts/2019/02")
+	assert(pattern == "/posts/:year<int>/:month<int>?" && params["month"] == "02", "optional param present wrong")
+
+	pattern, _ = testFind(root, "/posts/2019/feb")
+	assert(pattern == "", "optional param constraint should apply")
+}
+
+func TestTreeFindNoAlloc(t *testing.T) {
+	root := testTree("/users/", "/users/:userId", "/users/me/settings", "/sites/")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		lk := routeLookup{method: HttpGet}
+		root.find("/users/me/settings", &lk)
+	})
+	assert(allocs == 0, "plain path lookup should not allocate")
+}
+
+func TestTreeMethods(t *testing.T) {
+	root := &routeNode{}
+	for _, router := range []*Router{
+		NewMethodRouter(HttpGet, "/items/:id", nil, nil),
+		NewMethodRouter(HttpDelete, "/items/:id", nil, nil),
+		NewMethodRouter(HttpPost, "/items/new", nil, nil),
+		NewRouter("/any", nil, nil),
+	} {
+		if err := root.addRoute(router); err != nil {
+			panic(err)
+		}
+	}
+
+	lk := routeLookup{method: HttpHead}
+	router := root.find("/items/1", &lk)
+	assert(router != nil && router.Method == HttpGet, "HEAD should be served by GET")
+
+	lk = routeLookup{method: HttpPost}
+	router = root.find("/items/new", &lk)
+	assert(router != nil && router.Method == HttpPost, "method router wrong")
+
+	lk = routeLookup{method: HttpDelete}
+	router = root.find("/items/new", &lk)
+	assert(router != nil && router.Method == HttpDelete && lk.params.Map()["id"] == "new", "method should fall through to param route")
+
+	lk = routeLookup{method: HttpPut}
+	router = root.find("/items/1", &lk)
+	assert(router == nil && lk.matched != nil, "method mismatch should be reported")
+	assert(strings.Join(lk.matched.allowedMethods(), ",") == "DELETE,GET,HEAD", "allowed methods wrong")
+
+	lk = routeLookup{method: HttpPut}
+	router = root.find("/any", &lk)
+	assert(router != nil && router.Method == "", "any method router wrong")
+
+	err := root.addRoute(NewMethodRouter(HttpGet, "/items/:id", nil, nil))
+	assert(errors.Cause(err) == ErrRouteConflict, "duplicated method route not reported")
+}
@@ -0,0 +1,168 @@
+package goweb
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type uploadForm struct {
+	Title       string                  `form:"title,required"`
+	Avatar      *multipart.FileHeader   `form:"avatar,required"`
+	Attachments []*multipart.FileHeader `form:"attachments,max=2"`
+}
+
+func testMultipartBody(fields map[string]string, files map[string][]string) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	for name, value := range fields {
+		w.WriteField(name, value)
+	}
+	for name, contents := range files {
+		for i, content := range contents {
+			fw, _ := w.CreateFormFile(name, name+strconv.Itoa(i)+".txt")
+			fw.Write([]byte(content))
+		}
+	}
+	w.Close()
+	return body, w.FormDataContentType()
+}
+
+func testUpload(server *AppServer, path string, body *bytes.Buffer, contentType string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(HttpPost, path, body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, req)
+	return w
+}
+
+func TestUploadFiles(t *testing.T) {
+	server := NewAppServer(&AppServerConfig{})
+	server.AddMethodRouter(HttpPost, "/upload", func(req *Request, resp *Response, ctx *RequestContext) error {
+		form := &uploadForm{}
+		if err := req.FillForm(form); err != nil {
+			return err
+		}
+		f, err := form.Avatar.Open()
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		content, _ := io.ReadAll(f)
+		resp.WriteString(form.Title + " " + string(content) + " " + strconv.Itoa(len(form.Attachments)))
+		return nil
+	}, &RouterConfig{MaxMemory: 1 << 10, MaxBodySize: 1 << 16})
+	server.AddMethodRouter(HttpPost, "/stream", func(req *Request, resp *Response, ctx *RequestContext) error {
+		names := make([]string, 0)
+		err := req.EachPart(func(part *multipart.Part) error {
+			content, err := io.ReadAll(part)
+			names = append(names, part.FormName()+"="+string(content))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		resp.WriteString(strings.Join(names, ","))
+		return nil
+	}, &RouterConfig{StreamMultipart: true})
+	server.prepare()
+
+	body, contentType := testMultipartBody(map[string]string{"title": "me"}, map[string][]string{"avatar": {"face"}, "attachments": {"a", "b"}})
+	w := testUpload(server, "/upload", body, contentType)
+	assert(w.Code == 200 && w.Body.String() == "me face 2", "uploaded files not bound: "+w.Body.String())
+
+	body, contentType = testMultipartBody(map[string]string{"title": "me"}, map[string][]string{"attachments": {"a", "b", "c"}})
+	w = testUpload(server, "/upload", body, contentType)
+	assert(w.Code == 400 && strings.Contains(w.Body.String(), "avatar") && strings.Contains(w.Body.String(), "attachments"), "file fields not validated")
+
+	body, contentType = testMultipartBody(nil, map[string][]string{"avatar": {strings.Repeat("x", 1<<17)}})
+	w = testUpload(server, "/upload", body, contentType)
+	assert(w.Code == 413, "large body should be rejected")
+
+	body, contentType = testMultipartBody(map[string]string{"title": "me"}, map[string][]string{"avatar": {"face"}})
+	w = testUpload(server, "/stream", body, contentType)
+	assert(w.Code == 200 && w.Body.String() == "title=me,avatar=face", "multipart parts not streamed: "+w.Body.String())
+
+	req := httptest.NewRequest(HttpGet, "/upload", nil)
+	w = httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, req)
+	assert(w.Code == 405, "method should be checked before parsing params")
+}
+
+func TestBodyLimitsBeforeRouting(t *testing.T) {
+	server := NewAppServer(&AppServerConfig{})
+	hub := NewRouterHub("/limited/")
+	hub.AddFilter(BeforeFilter(func(req *Request, resp *Response, ctx *RequestContext) error {
+		// reading params before routing must honor the config of the matched router
+		ctx.Request.Param("token")
+		return nil
+	}))
+	hub.AddRouter(NewMethodRouter(HttpPost, "/limited/small", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return resp.WriteString("ok")
+	}, &RouterConfig{MaxBodySize: 10}))
+	hub.AddRouter(NewMethodRouter(HttpPost, "/limited/stream", func(req *Request, resp *Response, ctx *RequestContext) error {
+		names := make([]string, 0)
+		err := req.EachPart(func(part *multipart.Part) error {
+			names = append(names, part.FormName())
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return resp.WriteString(strings.Join(names, ","))
+	}, &RouterConfig{StreamMultipart: true}))
+	server.AddHub(hub)
+	server.prepare()
+
+	req := httptest.NewRequest(HttpPost, "/limited/small", io.MultiReader(strings.NewReader("token="), strings.NewReader(strings.Repeat("x", 96))))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.ContentLength = -1
+	req.TransferEncoding = []string{"chunked"}
+	w := httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, req)
+	assert(w.Code == 413, "chunked body over the limit should be rejected before routing: "+strconv.Itoa(w.Code))
+
+	body, contentType := testMultipartBody(map[string]string{"title": "me"}, map[string][]string{"avatar": {"face"}})
+	w = testUpload(server, "/limited/stream?token=t", body, contentType)
+	assert(w.Code == 200 && w.Body.String() == "title,avatar", "multipart body should not be consumed before routing: "+w.Body.String())
+}
+
+func TestBodyLimitsBeforeServerMiddlewares(t *testing.T) {
+	server := NewAppServer(&AppServerConfig{})
+	server.Use(func(next RequestHandlerFunc) RequestHandlerFunc {
+		return func(req *Request, resp *Response, ctx *RequestContext) error {
+			// reading params in a server middleware must honor the config of a router mounted directly
+			req.Param("token")
+			return next(req, resp, ctx)
+		}
+	})
+	server.AddRouter("/up", func(req *Request, resp *Response, ctx *RequestContext) error {
+		return resp.WriteString("ok")
+	}, &RouterConfig{MaxBodySize: 10})
+	server.AddRouter("/stream", func(req *Request, resp *Response, ctx *RequestContext) error {
+		names := make([]string, 0)
+		err := req.EachPart(func(part *multipart.Part) error {
+			names = append(names, part.FormName())
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return resp.WriteString(strings.Join(names, ","))
+	}, &RouterConfig{StreamMultipart: true})
+	server.prepare()
+
+	req := httptest.NewRequest(HttpPost, "/up", strings.NewReader("token="+strings.Repeat("x", 196)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	server.ServeMux.ServeHTTP(w, req)
+	assert(w.Code == 413, "body over the limit should be rejected before server middlewares: "+strconv.Itoa(w.Code))
+
+	body, contentType := testMultipartBody(map[string]string{"title": "me"}, map[string][]string{"avatar": {"face"}})
+	w = testUpload(server, "/stream?token=t", body, contentType)
+	assert(w.Code == 200 && w.Body.String() == "title,avatar", "multipart body should not be consumed by server middlewares: "+w.Body.String())
+}